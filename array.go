@@ -56,7 +56,7 @@ func arrayPtrIndex(L *lua.LState) int {
 			return 1
 		}
 
-		mt = MT(L, ref.Interface())
+		mt = Metatable{LTable: getMetatable(L, ref.Type())}
 		if fn := mt.method(string(converted)); fn != nil {
 			L.Push(fn)
 			return 1
@@ -111,7 +111,7 @@ func arrayCall(L *lua.LState) int {
 		return 2
 	}
 
-	L.Push(L.NewFunction(fn))
+	L.Push(newFunction(L, fn))
 	return 1
 }
 
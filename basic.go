@@ -0,0 +1,292 @@
+package luar
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+
+	"github.com/yuin/gopher-lua"
+)
+
+// isPreservedType reports whether values of t, a named bool, numeric, or
+// string type, are converted to userdata rather than plain Lua values.
+func isPreservedType(L *lua.LState, t reflect.Type) bool {
+	return t.PkgPath() != "" && isBasicKind(t.Kind()) && GetConfig(L).preserveNamed(t)
+}
+
+func isBasicKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Bool, reflect.String:
+		return true
+	}
+	return isNumericKind(k)
+}
+
+func isNumericKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// basicOperandType returns the type of the first operand of a binary
+// metamethod that is a userdata whose value's kind satisfies match.
+func basicOperandType(L *lua.LState, match func(reflect.Kind) bool) reflect.Type {
+	for i := 1; i <= 2; i++ {
+		if ud, ok := L.Get(i).(*lua.LUserData); ok {
+			if typ := reflect.TypeOf(ud.Value); typ != nil && match(typ.Kind()) {
+				return typ
+			}
+		}
+	}
+	L.RaiseError("no operand of a matching userdata type")
+	panic("never reaches")
+}
+
+func isStringKind(k reflect.Kind) bool {
+	return k == reflect.String
+}
+
+// basicOperands converts both operands of a binary metamethod to the named
+// numeric type of whichever operand is the userdata.
+func basicOperands(L *lua.LState) (a, b reflect.Value) {
+	typ := basicOperandType(L, isNumericKind)
+	return basicOperand(L, 1, typ), basicOperand(L, 2, typ)
+}
+
+// basicOperand converts the operand at idx to typ. Whatever
+// Config.Conversion is, numbers that typ cannot represent exactly (e.g. 2.5
+// for an integer type) raise an error rather than being truncated.
+func basicOperand(L *lua.LState, idx int, typ reflect.Type) reflect.Value {
+	lv := L.CheckAny(idx)
+	if n, ok := lv.(lua.LNumber); ok {
+		if reason := numberLoss(n, typ); reason != "" {
+			L.ArgError(idx, conversionError{
+				Lua:    n,
+				Hint:   typ,
+				Reason: reason,
+			}.Error())
+		}
+	}
+	val, err := lValueToReflect(L, lv, typ, nil)
+	if err != nil {
+		L.ArgError(idx, err.Error())
+	}
+	return val
+}
+
+func basicIndex(L *lua.LState) int {
+	_, mt := check(L, 1)
+	key := L.CheckString(2)
+
+	if fn := mt.method(key); fn != nil {
+		L.Push(fn)
+		return 1
+	}
+
+	return 0
+}
+
+func basicTostring(L *lua.LState) int {
+	ud := L.CheckUserData(1)
+	L.Push(lua.LString(fmt.Sprint(ud.Value)))
+	return 1
+}
+
+func basicEq(L *lua.LState) int {
+	ref1, _ := check(L, 1)
+	ref2, _ := check(L, 2)
+
+	L.Push(lua.LBool(ref1.Interface() == ref2.Interface()))
+	return 1
+}
+
+func basicCompare(L *lua.LState, a, b reflect.Value) int {
+	switch a.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		x, y := a.Int(), b.Int()
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		x, y := a.Uint(), b.Uint()
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+	case reflect.Float32, reflect.Float64:
+		x, y := a.Float(), b.Float()
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+	case reflect.String:
+		x, y := a.String(), b.String()
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+	default:
+		L.RaiseError("cannot compare values of type " + a.Type().String())
+	}
+	return 0
+}
+
+func basicLt(L *lua.LState) int {
+	ref1, _ := check(L, 1)
+	ref2, _ := check(L, 2)
+
+	L.Push(lua.LBool(basicCompare(L, ref1, ref2) < 0))
+	return 1
+}
+
+func basicLe(L *lua.LState) int {
+	ref1, _ := check(L, 1)
+	ref2, _ := check(L, 2)
+
+	L.Push(lua.LBool(basicCompare(L, ref1, ref2) <= 0))
+	return 1
+}
+
+// basicArith performs op on a and b using Go semantics for the operands'
+// kind, returning a value of the same named type.
+func basicArith(L *lua.LState, op string, a, b reflect.Value) reflect.Value {
+	ret := reflect.New(a.Type()).Elem()
+
+	switch a.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		x, y := a.Int(), b.Int()
+		var r int64
+		switch op {
+		case "add":
+			r = x + y
+		case "sub":
+			r = x - y
+		case "mul":
+			r = x * y
+		case "div", "mod":
+			if y == 0 {
+				L.RaiseError("integer divide by zero")
+			}
+			if op == "div" {
+				r = x / y
+			} else {
+				r = x % y
+			}
+		case "pow":
+			r = int64(math.Pow(float64(x), float64(y)))
+		}
+		ret.SetInt(r)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		x, y := a.Uint(), b.Uint()
+		var r uint64
+		switch op {
+		case "add":
+			r = x + y
+		case "sub":
+			r = x - y
+		case "mul":
+			r = x * y
+		case "div", "mod":
+			if y == 0 {
+				L.RaiseError("integer divide by zero")
+			}
+			if op == "div" {
+				r = x / y
+			} else {
+				r = x % y
+			}
+		case "pow":
+			r = uint64(math.Pow(float64(x), float64(y)))
+		}
+		ret.SetUint(r)
+	case reflect.Float32, reflect.Float64:
+		x, y := a.Float(), b.Float()
+		var r float64
+		switch op {
+		case "add":
+			r = x + y
+		case "sub":
+			r = x - y
+		case "mul":
+			r = x * y
+		case "div":
+			r = x / y
+		case "mod":
+			r = math.Mod(x, y)
+		case "pow":
+			r = math.Pow(x, y)
+		}
+		ret.SetFloat(r)
+	default:
+		L.RaiseError("cannot perform " + op + " operation on type " + a.Type().String())
+	}
+	return ret
+}
+
+func basicArithFunc(op string) lua.LGFunction {
+	return func(L *lua.LState) int {
+		a, b := basicOperands(L)
+		L.Push(New(L, basicArith(L, op, a, b).Interface()))
+		return 1
+	}
+}
+
+func basicUnm(L *lua.LState) int {
+	ref, _ := check(L, 1)
+
+	ret := reflect.New(ref.Type()).Elem()
+	switch ref.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		ret.SetInt(-ref.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		ret.SetUint(-ref.Uint())
+	case reflect.Float32, reflect.Float64:
+		ret.SetFloat(-ref.Float())
+	default:
+		L.RaiseError("cannot negate type " + ref.Type().String())
+	}
+	L.Push(New(L, ret.Interface()))
+	return 1
+}
+
+func basicLen(L *lua.LState) int {
+	ref, _ := check(L, 1)
+
+	L.Push(lua.LNumber(ref.Len()))
+	return 1
+}
+
+func basicConcatOperand(L *lua.LState, idx int) string {
+	switch v := L.CheckAny(idx).(type) {
+	case *lua.LUserData:
+		if ref := reflect.ValueOf(v.Value); ref.Kind() == reflect.String {
+			return ref.String()
+		}
+	case lua.LString, lua.LNumber:
+		return lua.LVAsString(v)
+	}
+	L.ArgError(idx, "cannot concatenate "+L.Get(idx).Type().String())
+	panic("never reaches")
+}
+
+func basicConcat(L *lua.LState) int {
+	typ := basicOperandType(L, isStringKind)
+
+	ret := reflect.New(typ).Elem()
+	ret.SetString(basicConcatOperand(L, 1) + basicConcatOperand(L, 2))
+	L.Push(New(L, ret.Interface()))
+	return 1
+}
@@ -0,0 +1,114 @@
+package luar
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/yuin/gopher-lua"
+)
+
+type TestBasicStatus int
+
+func (s TestBasicStatus) String() string {
+	switch s {
+	case 0:
+		return "pending"
+	case 1:
+		return "running"
+	case 2:
+		return "done"
+	}
+	return "unknown"
+}
+
+func (s TestBasicStatus) IsTerminal() bool {
+	return s == 2
+}
+
+type TestBasicName string
+
+func (n TestBasicName) Greeting() string {
+	return "Hello, " + string(n)
+}
+
+type TestBasicRatio float64
+
+func Test_basic_named_disabled(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	L.SetGlobal("s", New(L, TestBasicStatus(2)))
+
+	testReturn(t, L, `return type(s), s`, "number", "2")
+}
+
+func Test_basic_named(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	GetConfig(L).NamedTypes = func(reflect.Type) bool {
+		return true
+	}
+
+	L.SetGlobal("s", New(L, TestBasicStatus(1)))
+	L.SetGlobal("done", New(L, TestBasicStatus(2)))
+	L.SetGlobal("n", New(L, TestBasicName("Tim")))
+	L.SetGlobal("r", New(L, TestBasicRatio(1.5)))
+	L.SetGlobal("i", New(L, 5))
+
+	testReturn(t, L, `return type(i)`, "number")
+	testReturn(t, L, `return type(s), tostring(s)`, "userdata", "running")
+	testReturn(t, L, `return s:IsTerminal(), done:isTerminal()`, "false", "true")
+	testReturn(t, L, `return tostring(s + 1), (s + 1) == done`, "done", "true")
+	testReturn(t, L, `return tostring(done / 2), tostring(-s)`, "running", "unknown")
+	testReturn(t, L, `return s < done, done <= s, s == s`, "true", "false", "true")
+	testReturn(t, L, `return tostring(r * 3), tostring(r % 1)`, "4.5", "0.5")
+	testReturn(t, L, `return n:Greeting(), #n`, "Hello, Tim", "3")
+	testReturn(t, L, `return (n .. "my"):greeting()`, "Hello, Timmy")
+	testError(t, L, `return s / 0`, "integer divide by zero")
+	testError(t, L, `return s + 2.5`, "fractional part 0.5 would be lost")
+	testError(t, L, `return 2.5 * s`, "bad argument #1 to (anonymous) (cannot use 2.5 (type lua.LNumber) as type luar.TestBasicStatus: fractional part 0.5 would be lost)")
+}
+
+func Test_basic_named_predicate(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	GetConfig(L).NamedTypes = func(t reflect.Type) bool {
+		return t == reflect.TypeOf(TestBasicStatus(0))
+	}
+
+	L.SetGlobal("s", New(L, TestBasicStatus(0)))
+	L.SetGlobal("n", New(L, TestBasicName("Tim")))
+
+	testReturn(t, L, `return type(s), type(n)`, "userdata", "string")
+}
+
+func Test_basic_named_convert(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	GetConfig(L).NamedTypes = func(reflect.Type) bool {
+		return true
+	}
+
+	var got TestBasicStatus
+	L.SetGlobal("set", New(L, func(s TestBasicStatus) {
+		got = s
+	}))
+	L.SetGlobal("s", New(L, TestBasicStatus(1)))
+
+	testReturn(t, L, `set(s + 1)`)
+	if got != 2 {
+		t.Fatalf("expecting 2, got %d", got)
+	}
+
+	testReturn(t, L, `set(0)`)
+	if got != 0 {
+		t.Fatalf("expecting 0, got %d", got)
+	}
+
+	status := TestBasicStatus(2)
+	L.SetGlobal("p", New(L, &status))
+	testReturn(t, L, `return p:IsTerminal()`, "true")
+}
@@ -0,0 +1,184 @@
+package luar
+
+import (
+	"testing"
+
+	"github.com/yuin/gopher-lua"
+)
+
+type benchPerson struct {
+	Name string
+	Age  int
+}
+
+func (p *benchPerson) Birthday() int {
+	p.Age++
+	return p.Age
+}
+
+func (p benchPerson) Greet(greeting string) string {
+	return greeting
+}
+
+// benchmarkScript runs script b.N times in a single Lua loop.
+//
+// The metatable of a userdata is read from the userdata itself, so indexing
+// does not look up the type cache. The allocations that remain are made
+// outside of luar's lookups:
+//   - gopher-lua boxes the key string when it calls an __index or
+//     __newindex metamethod (method calls, map and field access);
+//   - reflect allocates the slice of results, and the results themselves,
+//     of functions called through reflect.Value.Call;
+//   - numbers and strings are boxed when they are returned to Lua as a
+//     lua.LValue, or passed to reflect as a map key or argument;
+//   - converting a table allocates the struct, and gopher-lua allocates the
+//     table's hash part when the script builds it.
+func benchmarkScript(b *testing.B, setup func(L *lua.LState), script string) {
+	L := lua.NewState()
+	defer L.Close()
+	setup(L)
+
+	fn, err := L.LoadString(`local n = ... for i = 1, n do ` + script + ` end`)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	L.Push(fn)
+	L.Push(lua.LNumber(b.N))
+	if err := L.PCall(1, 0, nil); err != nil {
+		b.Fatal(err)
+	}
+}
+
+func setPerson(L *lua.LState) {
+	L.SetGlobal("p", New(L, &benchPerson{Name: "bob"}))
+}
+
+func Benchmark_call_func(b *testing.B) {
+	benchmarkScript(b, func(L *lua.LState) {
+		L.SetGlobal("add", New(L, func(a, b int) int {
+			return a + b
+		}))
+	}, `add(i, 1)`)
+}
+
+func Benchmark_call_method(b *testing.B) {
+	benchmarkScript(b, setPerson, `p:Birthday()`)
+}
+
+func Benchmark_call_value_method(b *testing.B) {
+	benchmarkScript(b, setPerson, `p:Greet("hi")`)
+}
+
+func Benchmark_struct_field_get(b *testing.B) {
+	benchmarkScript(b, setPerson, `local _ = p.Age`)
+}
+
+func Benchmark_struct_field_set(b *testing.B) {
+	benchmarkScript(b, setPerson, `p.Age = i`)
+}
+
+func Benchmark_slice_index(b *testing.B) {
+	benchmarkScript(b, func(L *lua.LState) {
+		L.SetGlobal("s", New(L, []int{1, 2, 3}))
+	}, `local _ = s[2]`)
+}
+
+func Benchmark_map_index(b *testing.B) {
+	benchmarkScript(b, func(L *lua.LState) {
+		L.SetGlobal("m", New(L, map[string]int{"a": 1}))
+	}, `local _ = m.a`)
+}
+
+func Benchmark_table_to_struct(b *testing.B) {
+	benchmarkScript(b, func(L *lua.LState) {
+		L.SetGlobal("take", New(L, func(p benchPerson) {}))
+	}, `take({Name = "bob", Age = 3})`)
+}
+
+type benchSlowFunc func(s string) string
+
+func Benchmark_call_fastpath(b *testing.B) {
+	benchmarkScript(b, func(L *lua.LState) {
+		L.SetGlobal("f", New(L, func(s string) string { return s }))
+	}, `f("x")`)
+}
+
+func Benchmark_call_fastpath_disabled(b *testing.B) {
+	benchmarkScript(b, func(L *lua.LState) {
+		// named function types do not use the fast path
+		L.SetGlobal("f", New(L, benchSlowFunc(func(s string) string { return s })))
+	}, `f("x")`)
+}
+
+func benchmarkCallback(b *testing.B, call func(L *lua.LState, fn *lua.LFunction) func(int) int) {
+	L := lua.NewState()
+	defer L.Close()
+
+	if err := L.DoString(`function callback(i) return i + 1 end`); err != nil {
+		b.Fatal(err)
+	}
+	fn := call(L, L.GetGlobal("callback").(*lua.LFunction))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		fn(i)
+	}
+}
+
+func Benchmark_callback(b *testing.B) {
+	benchmarkCallback(b, func(L *lua.LState, fn *lua.LFunction) func(int) int {
+		var callback func(int) int
+		L.SetGlobal("set", New(L, func(f func(int) int) {
+			callback = f
+		}))
+		L.Push(L.GetGlobal("set"))
+		L.Push(fn)
+		L.Call(1, 0)
+		return callback
+	})
+}
+
+// Benchmark_callback_newthread calls the Lua function using a new thread for
+// each call, for comparison with Benchmark_callback.
+func Benchmark_callback_newthread(b *testing.B) {
+	benchmarkCallback(b, func(L *lua.LState, fn *lua.LFunction) func(int) int {
+		return func(i int) int {
+			thread, cancel := L.NewThread()
+			if cancel != nil {
+				defer cancel()
+			}
+			thread.Push(fn)
+			thread.Push(lua.LNumber(i))
+			thread.Call(1, 1)
+			return int(thread.Get(-1).(lua.LNumber))
+		}
+	})
+}
+
+func benchmarkBigTable(L *lua.LState) {
+	if err := L.DoString(`big = {} for i = 1, 1000 do big[i] = i end`); err != nil {
+		panic(err)
+	}
+}
+
+func Benchmark_table_copy(b *testing.B) {
+	benchmarkScript(b, func(L *lua.LState) {
+		benchmarkBigTable(L)
+		L.SetGlobal("first", New(L, func(s []int) int { return s[0] }))
+	}, `first(big)`)
+}
+
+func Benchmark_table_view(b *testing.B) {
+	benchmarkScript(b, func(L *lua.LState) {
+		benchmarkBigTable(L)
+		L.SetGlobal("first", New(L, func(t *Table) int {
+			var first int
+			t.Get(1, &first)
+			return first
+		}))
+	}, `first(big)`)
+}
@@ -0,0 +1,254 @@
+package luar
+
+import (
+	"math"
+	"math/big"
+	"reflect"
+
+	"github.com/yuin/gopher-lua"
+)
+
+var (
+	refTypeBigInt   = reflect.TypeOf((*big.Int)(nil))
+	refTypeBigFloat = reflect.TypeOf((*big.Float)(nil))
+	refTypeBigRat   = reflect.TypeOf((*big.Rat)(nil))
+)
+
+func isBigType(t reflect.Type) bool {
+	return t == refTypeBigInt || t == refTypeBigFloat || t == refTypeBigRat
+}
+
+// bigFromLValue converts a Lua number or string to a new value of the big
+// type t. ok is false if v cannot be represented by t.
+func bigFromLValue(v lua.LValue, t reflect.Type) (val reflect.Value, ok bool) {
+	switch converted := v.(type) {
+	case lua.LNumber:
+		f := float64(converted)
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return reflect.Value{}, false
+		}
+		switch t {
+		case refTypeBigInt:
+			if f != math.Trunc(f) {
+				return reflect.Value{}, false
+			}
+			i, _ := big.NewFloat(f).Int(nil)
+			return reflect.ValueOf(i), true
+		case refTypeBigFloat:
+			return reflect.ValueOf(big.NewFloat(f)), true
+		case refTypeBigRat:
+			return reflect.ValueOf(new(big.Rat).SetFloat64(f)), true
+		}
+	case lua.LString:
+		s := string(converted)
+		switch t {
+		case refTypeBigInt:
+			i, ok := new(big.Int).SetString(s, 0)
+			return reflect.ValueOf(i), ok
+		case refTypeBigFloat:
+			f, ok := new(big.Float).SetString(s)
+			return reflect.ValueOf(f), ok
+		case refTypeBigRat:
+			r, ok := new(big.Rat).SetString(s)
+			return reflect.ValueOf(r), ok
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// bigOperands converts both operands of a binary metamethod to the big type
+// of whichever operand is the userdata.
+func bigOperands(L *lua.LState) (a, b interface{}) {
+	var typ reflect.Type
+	for i := 1; i <= 2 && typ == nil; i++ {
+		if ud, ok := L.Get(i).(*lua.LUserData); ok && isBigType(reflect.TypeOf(ud.Value)) {
+			typ = reflect.TypeOf(ud.Value)
+		}
+	}
+	if typ == nil {
+		L.RaiseError("no operand of a math/big type")
+	}
+
+	x, err := lValueToReflect(L, L.CheckAny(1), typ, nil)
+	if err != nil {
+		L.ArgError(1, err.Error())
+	}
+	y, err := lValueToReflect(L, L.CheckAny(2), typ, nil)
+	if err != nil {
+		L.ArgError(2, err.Error())
+	}
+	if x.IsNil() || y.IsNil() {
+		L.RaiseError("nil " + typ.String() + " operand")
+	}
+	return x.Interface(), y.Interface()
+}
+
+func bigArithFunc(op string) lua.LGFunction {
+	return func(L *lua.LState) int {
+		a, b := bigOperands(L)
+
+		// *big.Float operations panic when the result would be NaN.
+		defer func() {
+			if r := recover(); r != nil {
+				if nan, ok := r.(big.ErrNaN); ok {
+					L.RaiseError(nan.Error())
+				}
+				panic(r)
+			}
+		}()
+
+		var ret interface{}
+		switch x := a.(type) {
+		case *big.Int:
+			y := b.(*big.Int)
+			z := new(big.Int)
+			switch op {
+			case "add":
+				z.Add(x, y)
+			case "sub":
+				z.Sub(x, y)
+			case "mul":
+				z.Mul(x, y)
+			case "div", "mod":
+				if y.Sign() == 0 {
+					L.RaiseError("integer divide by zero")
+				}
+				if op == "div" {
+					z.Quo(x, y)
+				} else {
+					// floored, like Lua's %: the result has the sign of
+					// the divisor
+					z.Mod(x, y)
+					if y.Sign() < 0 && z.Sign() != 0 {
+						z.Add(z, y)
+					}
+				}
+			case "pow":
+				if y.Sign() < 0 {
+					L.RaiseError("negative exponent")
+				}
+				z.Exp(x, y, nil)
+			}
+			ret = z
+		case *big.Float:
+			y := b.(*big.Float)
+			z := new(big.Float)
+			switch op {
+			case "add":
+				z.Add(x, y)
+			case "sub":
+				z.Sub(x, y)
+			case "mul":
+				z.Mul(x, y)
+			case "div":
+				z.Quo(x, y)
+			}
+			ret = z
+		case *big.Rat:
+			y := b.(*big.Rat)
+			z := new(big.Rat)
+			switch op {
+			case "add":
+				z.Add(x, y)
+			case "sub":
+				z.Sub(x, y)
+			case "mul":
+				z.Mul(x, y)
+			case "div":
+				if y.Sign() == 0 {
+					L.RaiseError("division by zero")
+				}
+				z.Quo(x, y)
+			}
+			ret = z
+		}
+		L.Push(New(L, ret))
+		return 1
+	}
+}
+
+func bigCmp(L *lua.LState) int {
+	a, b := bigOperands(L)
+
+	switch x := a.(type) {
+	case *big.Int:
+		return x.Cmp(b.(*big.Int))
+	case *big.Float:
+		return x.Cmp(b.(*big.Float))
+	case *big.Rat:
+		return x.Cmp(b.(*big.Rat))
+	}
+	panic("never reaches")
+}
+
+func bigEq(L *lua.LState) int {
+	L.Push(lua.LBool(bigCmp(L) == 0))
+	return 1
+}
+
+func bigLt(L *lua.LState) int {
+	L.Push(lua.LBool(bigCmp(L) < 0))
+	return 1
+}
+
+func bigLe(L *lua.LState) int {
+	L.Push(lua.LBool(bigCmp(L) <= 0))
+	return 1
+}
+
+func bigUnm(L *lua.LState) int {
+	ud := L.CheckUserData(1)
+
+	var ret interface{}
+	switch x := ud.Value.(type) {
+	case *big.Int:
+		ret = new(big.Int).Neg(x)
+	case *big.Float:
+		ret = new(big.Float).Neg(x)
+	case *big.Rat:
+		ret = new(big.Rat).Neg(x)
+	default:
+		L.ArgError(1, "expecting math/big value")
+	}
+	L.Push(New(L, ret))
+	return 1
+}
+
+func bigTostring(L *lua.LState) int {
+	ud := L.CheckUserData(1)
+
+	var str string
+	switch x := ud.Value.(type) {
+	case *big.Int:
+		str = x.String()
+	case *big.Float:
+		str = x.Text('g', -1)
+	case *big.Rat:
+		str = x.RatString()
+	default:
+		L.ArgError(1, "expecting math/big value")
+	}
+	L.Push(lua.LString(str))
+	return 1
+}
+
+func addBigMetamethods(L *lua.LState, mt *lua.LTable, vtype reflect.Type) {
+	mt.RawSetString("__eq", newFunction(L, bigEq))
+	mt.RawSetString("__lt", newFunction(L, bigLt))
+	mt.RawSetString("__le", newFunction(L, bigLe))
+	mt.RawSetString("__add", newFunction(L, bigArithFunc("add")))
+	mt.RawSetString("__sub", newFunction(L, bigArithFunc("sub")))
+	mt.RawSetString("__mul", newFunction(L, bigArithFunc("mul")))
+	mt.RawSetString("__div", newFunction(L, bigArithFunc("div")))
+	mt.RawSetString("__unm", newFunction(L, bigUnm))
+	mt.RawSetString("__tostring", newFunction(L, bigTostring))
+
+	if vtype == refTypeBigInt {
+		mt.RawSetString("__mod", newFunction(L, bigArithFunc("mod")))
+		mt.RawSetString("__pow", newFunction(L, bigArithFunc("pow")))
+	} else {
+		// the pointer assignment operator is removed, as it would be taken
+		// for exponentiation; math/big values must not be copied anyway
+		mt.RawSetString("__pow", lua.LNil)
+	}
+}
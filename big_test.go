@@ -0,0 +1,68 @@
+package luar
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/yuin/gopher-lua"
+)
+
+func Test_big_int(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	a, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+
+	L.SetGlobal("a", New(L, a))
+	L.SetGlobal("Int", NewType(L, big.Int{}))
+
+	testReturn(t, L, `return tostring(a + 10)`, "123456789012345678901234567900")
+	testReturn(t, L, `return tostring(a - "123456789012345678901234567889")`, "1")
+	testReturn(t, L, `return tostring(Int(2) ^ 100)`, "1267650600228229401496703205376")
+	testReturn(t, L, `return tostring(Int(7) / 2), tostring(Int(-7) % 2), tostring(-Int(3))`, "3", "1", "-3")
+	testReturn(t, L, `return tostring(Int(7) % -2), tostring(Int(-7) % -2), tostring(Int(6) % -2)`, "-1", "-1", "0")
+	testReturn(t, L, `return Int(3) < Int(4), Int(4) <= Int(4), Int("0x10") == Int(16)`, "true", "true", "true")
+	testReturn(t, L, `return a:Sign(), a:BitLen()`, "1", "97")
+	testError(t, L, `return a / 0`, "integer divide by zero")
+	testError(t, L, `return a + 1.5`, "cannot use 1.5")
+	testError(t, L, `return Int("abc")`, "cannot convert to big.Int")
+
+	if s := a.String(); s != "123456789012345678901234567890" {
+		t.Fatalf("operand modified: %s", s)
+	}
+}
+
+func Test_big_float(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	L.SetGlobal("Float", NewType(L, big.Float{}))
+
+	testReturn(t, L, `return tostring(Float("1.5") * 2)`, "3")
+	testReturn(t, L, `return tostring(Float(1) / 4 - 0.25)`, "0")
+	testReturn(t, L, `return Float("0.1") < Float("0.2")`, "true")
+	testError(t, L, `local inf = Float("+Inf"); return inf - inf`, "subtraction of infinities")
+	testError(t, L, `return Float(2) ^ 2`, "cannot perform pow operation")
+}
+
+func Test_big_rat(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	L.SetGlobal("Rat", NewType(L, big.Rat{}))
+
+	var got *big.Rat
+	L.SetGlobal("set", New(L, func(r *big.Rat) {
+		got = r
+	}))
+
+	testReturn(t, L, `return tostring(Rat("1/3") + Rat("1/6"))`, "1/2")
+	testReturn(t, L, `return tostring(Rat(0.5) * 4), tostring(Rat("2/3") / "1/3")`, "2", "2")
+	testReturn(t, L, `return Rat("1/3") == Rat("2/6")`, "true")
+	testError(t, L, `return Rat(1) / 0`, "division by zero")
+
+	testReturn(t, L, `set("3/4")`)
+	if got.Cmp(big.NewRat(3, 4)) != 0 {
+		t.Fatalf("expecting 3/4, got %s", got)
+	}
+}
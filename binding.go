@@ -0,0 +1,136 @@
+package luar
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/yuin/gopher-lua"
+)
+
+// Binding holds functions, typically generated by cmd/luargen, that access
+// the fields and call the methods of a struct type without reflection.
+//
+// Each function may decline to handle a particular access, in which case the
+// access is performed using reflection, as for types without a binding. A
+// binding therefore only needs to handle the fields and methods it can access
+// more efficiently.
+type Binding struct {
+	// Get pushes the field named key of value, which is either a value of the
+	// struct type or a pointer to one, and returns true. It returns false if
+	// it does not handle the field.
+	Get func(L *lua.LState, value interface{}, key string) bool
+
+	// Set sets the field named key of value, a pointer to the struct type, to
+	// lv, and returns true. It returns false if it does not handle the field,
+	// or if lv cannot be stored in the field without conversion.
+	Set func(L *lua.LState, value interface{}, key string, lv lua.LValue) bool
+
+	// Methods holds functions that call the method with the given Go name
+	// using the arguments on L's stack, the first of which is the receiver.
+	// Like a FastPath, a function returns the number of values it pushed,
+	// or -1 if it does not handle the arguments.
+	Methods map[string]func(L *lua.LState) int
+}
+
+var bindings = struct {
+	sync.RWMutex
+	m map[reflect.Type]*Binding
+}{
+	m: make(map[reflect.Type]*Binding),
+}
+
+// RegisterBinding registers b as the binding of the struct type of sample,
+// which must be a struct or a pointer to a struct (it may be nil). The
+// binding is used for values of the type, and pointers to it, by states that
+// convert them after it is registered. It is typically called from the init
+// function of a generated file.
+//
+// Get and Set are not used by states whose Config.FieldNames is set (or whose
+// Config.Registry has a field naming function), as the binding's field names
+// were generated using the default behaviour.
+func RegisterBinding(sample interface{}, b *Binding) {
+	t := reflect.TypeOf(sample)
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		panic("luar: RegisterBinding expects a struct or a pointer to a struct")
+	}
+
+	bindings.Lock()
+	defer bindings.Unlock()
+	if b == nil {
+		delete(bindings.m, t)
+		return
+	}
+	bindings.m[t] = b
+}
+
+// getBinding returns the binding of the struct type t, or of the struct type
+// t points to.
+func getBinding(t reflect.Type) *Binding {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	bindings.RLock()
+	defer bindings.RUnlock()
+	return bindings.m[t]
+}
+
+func (b *Binding) method(name string) func(L *lua.LState) int {
+	if b == nil {
+		return nil
+	}
+	return b.Methods[name]
+}
+
+// bindingFields reports whether the field functions of bindings can be used
+// with c, i.e. whether c uses the default field names.
+func bindingFields(c *Config) bool {
+	return c.FieldNames == nil && (c.Registry == nil || c.Registry.fieldNames == nil)
+}
+
+// bindingIndex returns an __index metamethod that uses b.Get for fields,
+// falling back to index. Methods take precedence over fields, as in
+// Metatable.member, so b.Get is not used for keys that name a method.
+func bindingIndex(b *Binding, index lua.LGFunction) lua.LGFunction {
+	return func(L *lua.LState) int {
+		ud := L.CheckUserData(1)
+		key := L.CheckString(2)
+		mt := Metatable{LTable: ud.Metatable.(*lua.LTable)}
+		if mt.method(key) == nil && b.Get(L, ud.Value, key) {
+			return 1
+		}
+		return index(L)
+	}
+}
+
+func bindingNewIndex(b *Binding) lua.LGFunction {
+	return func(L *lua.LState) int {
+		ud := L.CheckUserData(1)
+		if b.Set(L, ud.Value, L.CheckString(2), L.CheckAny(3)) {
+			return 0
+		}
+		return structPtrNewIndex(L)
+	}
+}
+
+// bindingMethod returns a wrapper of the method fn that calls it using path,
+// falling back to funcRegular.
+func bindingMethod(L *lua.LState, fn reflect.Value, isPtrReceiverMethod bool, path func(L *lua.LState) int) *lua.LFunction {
+	up := L.NewUserData()
+	up.Value = fn
+
+	call := func(L *lua.LState) int {
+		if n := path(L); n >= 0 {
+			return n
+		}
+		return funcRegular(L)
+	}
+	return L.NewClosure(protect(call, func() string {
+		return funcName(fn)
+	}), up, lua.LBool(isPtrReceiverMethod))
+}
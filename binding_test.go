@@ -0,0 +1,109 @@
+package luar
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/yuin/gopher-lua"
+)
+
+type bindingTestPoint struct {
+	X, Y  int
+	Label string
+}
+
+func (p bindingTestPoint) Sum() int {
+	return p.X + p.Y
+}
+
+func Test_binding(t *testing.T) {
+	var gets, sets, calls int
+	RegisterBinding(bindingTestPoint{}, &Binding{
+		Get: func(L *lua.LState, value interface{}, key string) bool {
+			var p *bindingTestPoint
+			switch v := value.(type) {
+			case bindingTestPoint:
+				p = &v
+			case *bindingTestPoint:
+				p = v
+			}
+			if key != "X" {
+				return false
+			}
+			gets++
+			L.Push(lua.LNumber(p.X))
+			return true
+		},
+		Set: func(L *lua.LState, value interface{}, key string, lv lua.LValue) bool {
+			n, ok := lv.(lua.LNumber)
+			if key != "X" || !ok || !(n >= -1<<31 && n < 1<<31) || n != lua.LNumber(int(n)) {
+				return false
+			}
+			sets++
+			value.(*bindingTestPoint).X = int(n)
+			return true
+		},
+		Methods: map[string]func(L *lua.LState) int{
+			"Sum": func(L *lua.LState) int {
+				if L.GetTop() != 1 {
+					return -1
+				}
+				p, ok := L.CheckUserData(1).Value.(bindingTestPoint)
+				if !ok {
+					return -1
+				}
+				calls++
+				L.Push(lua.LNumber(p.Sum()))
+				return 1
+			},
+		},
+	})
+	defer RegisterBinding(bindingTestPoint{}, nil)
+
+	L := lua.NewState()
+	defer L.Close()
+
+	p := &bindingTestPoint{X: 1, Y: 2, Label: "a"}
+	L.SetGlobal("p", New(L, p))
+	L.SetGlobal("v", New(L, *p))
+
+	testReturn(t, L, `return p.X, p.x, p.Y, p.label`, "1", "1", "2", "a")
+	testReturn(t, L, `p.X = 10; p.Y = 20; return p.X, p.Y`, "10", "20")
+	testReturn(t, L, `p.X = 5.5; return p.X`, "5")
+	testReturn(t, L, `return v.X, v:Sum(), v:sum()`, "1", "3", "3")
+	testReturn(t, L, `return p:Sum()`, "25")
+	testError(t, L, `p.X = {}`, "cannot use")
+	testError(t, L, `p.Z = 1`, "unknown field Z")
+	testError(t, L, `return v:Sum(1)`, "invalid number of function arguments")
+
+	if gets != 4 || sets != 1 || calls != 2 {
+		t.Fatalf("unexpected binding usage: %d gets, %d sets, %d calls", gets, sets, calls)
+	}
+	if p.X != 5 || p.Y != 20 {
+		t.Fatalf("unexpected value %+v", *p)
+	}
+}
+
+func Test_binding_fieldnames(t *testing.T) {
+	gets := 0
+	RegisterBinding((*bindingTestPoint)(nil), &Binding{
+		Get: func(L *lua.LState, value interface{}, key string) bool {
+			gets++
+			L.Push(lua.LString("binding"))
+			return true
+		},
+	})
+	defer RegisterBinding((*bindingTestPoint)(nil), nil)
+
+	L := lua.NewState()
+	defer L.Close()
+	GetConfig(L).FieldNames = func(s reflect.Type, f reflect.StructField) []string {
+		return []string{"go_" + f.Name}
+	}
+
+	L.SetGlobal("p", New(L, &bindingTestPoint{X: 1}))
+	testReturn(t, L, `return p.go_X, p.X`, "1", "nil")
+	if gets != 0 {
+		t.Fatalf("expecting binding not to be used, got %d gets", gets)
+	}
+}
@@ -6,18 +6,65 @@ import (
 	"github.com/yuin/gopher-lua"
 )
 
-func addMethods(L *lua.LState, c *Config, vtype reflect.Type, tbl *lua.LTable, ptrReceiver bool) {
+// methodInfo is an exported method of a type, and the names under which it
+// is accessed.
+type methodInfo struct {
+	name  string
+	fn    reflect.Value
+	names []string
+}
+
+// fieldInfo is the index of an accessible struct field, and the names under
+// which it is accessed.
+type fieldInfo struct {
+	accessor *fieldAccessor
+	names    []string
+}
+
+func collectMethods(vtype reflect.Type, namesFn func(t reflect.Type, m reflect.Method) []string) []methodInfo {
+	if namesFn == nil {
+		namesFn = defaultMethodNames
+	}
+
+	methods := make([]methodInfo, 0, vtype.NumMethod())
 	for i := 0; i < vtype.NumMethod(); i++ {
 		method := vtype.Method(i)
 		if method.PkgPath != "" {
 			continue
 		}
-		namesFn := c.MethodNames
-		if namesFn == nil {
-			namesFn = defaultMethodNames
+		methods = append(methods, methodInfo{
+			name:  method.Name,
+			fn:    method.Func,
+			names: namesFn(vtype, method),
+		})
+	}
+	return methods
+}
+
+func addMethods(L *lua.LState, c *Config, vtype reflect.Type, tbl *lua.LTable, ptrReceiver bool) {
+	var methods []methodInfo
+	if c.Registry != nil {
+		methods = c.Registry.lookup(vtype).methods
+	} else {
+		methods = collectMethods(vtype, c.MethodNames)
+	}
+
+	binding := getBinding(vtype)
+	closer := vtype.Implements(refTypeCloser)
+	for _, method := range methods {
+		if vtype == refTypeFuture && futureMethodHidden(method.name) {
+			continue
+		}
+		var fn *lua.LFunction
+		if path := binding.method(method.name); path != nil {
+			fn = bindingMethod(L, method.fn, ptrReceiver, path)
+		} else {
+			fn = funcWrapper(L, method.fn, ptrReceiver)
+		}
+		if closer && method.name == "Close" {
+			fn = closerMethod(L, fn)
 		}
-		fn := funcWrapper(L, method.Func, ptrReceiver)
-		for _, name := range namesFn(vtype, method) {
+		for _, name := range method.names {
 			tbl.RawSetString(name, fn)
 		}
 	}
@@ -71,24 +118,59 @@ func collectFields(vtype reflect.Type, current []int) map[string]reflect.StructF
 	return m
 }
 
-func addFields(L *lua.LState, c *Config, vtype reflect.Type, tbl *lua.LTable) {
-	namesFn := c.FieldNames
+func namedFields(vtype reflect.Type, namesFn func(s reflect.Type, f reflect.StructField) []string) []fieldInfo {
 	if namesFn == nil {
 		namesFn = defaultFieldNames
 	}
 
+	var fields []fieldInfo
 	for _, field := range collectFields(vtype, nil) {
-		aliases := namesFn(vtype, field)
-		if len(aliases) > 0 {
-			ud := L.NewUserData()
-			ud.Value = field.Index
-			for _, alias := range aliases {
-				tbl.RawSetString(alias, ud)
-			}
+		if isExtraField(field) {
+			// unknown keys are collected in the extra field, which is
+			// therefore not accessible by name
+			continue
+		}
+		if names := namesFn(vtype, field); len(names) > 0 {
+			fields = append(fields, fieldInfo{
+				accessor: newFieldAccessor(vtype, field.Index),
+				names:    names,
+			})
+		}
+	}
+	return fields
+}
+
+func addFields(L *lua.LState, c *Config, vtype reflect.Type, tbl *lua.LTable) {
+	var fields []fieldInfo
+	if c.Registry != nil {
+		fields = c.Registry.lookup(vtype).fields
+	} else {
+		fields = namedFields(vtype, c.FieldNames)
+	}
+
+	for _, field := range fields {
+		ud := L.NewUserData()
+		ud.Value = field.accessor
+		for _, alias := range field.names {
+			tbl.RawSetString(alias, ud)
 		}
 	}
 }
 
+// addMembers adds the members table of a struct or struct pointer
+// metatable, which holds both the fields and methods of the type so that they
+// can be found with a single lookup.
+func addMembers(L *lua.LState, mt, fields, methods *lua.LTable) {
+	members := L.CreateTable(0, 0)
+	fields.ForEach(func(name, field lua.LValue) {
+		members.RawSet(name, field)
+	})
+	methods.ForEach(func(name, method lua.LValue) {
+		members.RawSet(name, method)
+	})
+	mt.RawSetString("members", members)
+}
+
 func getMetatable(L *lua.LState, vtype reflect.Type) *lua.LTable {
 	config := GetConfig(L)
 
@@ -105,39 +187,39 @@ func getMetatable(L *lua.LState, vtype reflect.Type) *lua.LTable {
 	case reflect.Array:
 		mt = L.CreateTable(0, 7)
 
-		mt.RawSetString("__index", L.NewFunction(arrayIndex))
-		mt.RawSetString("__len", L.NewFunction(arrayLen))
-		mt.RawSetString("__call", L.NewFunction(arrayCall))
-		mt.RawSetString("__eq", L.NewFunction(arrayEq))
+		mt.RawSetString("__index", newFunction(L, arrayIndex))
+		mt.RawSetString("__len", newFunction(L, arrayLen))
+		mt.RawSetString("__call", newFunction(L, arrayCall))
+		mt.RawSetString("__eq", newFunction(L, arrayEq))
 
 		addMethods(L, config, vtype, methods, false)
 	case reflect.Chan:
 		mt = L.CreateTable(0, 8)
 
-		mt.RawSetString("__index", L.NewFunction(chanIndex))
-		mt.RawSetString("__len", L.NewFunction(chanLen))
-		mt.RawSetString("__eq", L.NewFunction(chanEq))
-		mt.RawSetString("__call", L.NewFunction(chanCall))
-		mt.RawSetString("__unm", L.NewFunction(chanUnm))
+		mt.RawSetString("__index", newFunction(L, chanIndex))
+		mt.RawSetString("__len", newFunction(L, chanLen))
+		mt.RawSetString("__eq", newFunction(L, chanEq))
+		mt.RawSetString("__call", newFunction(L, chanCall))
+		mt.RawSetString("__unm", newFunction(L, chanUnm))
 
 		addMethods(L, config, vtype, methods, false)
 	case reflect.Map:
 		mt = L.CreateTable(0, 7)
 
-		mt.RawSetString("__index", L.NewFunction(mapIndex))
-		mt.RawSetString("__newindex", L.NewFunction(mapNewIndex))
-		mt.RawSetString("__len", L.NewFunction(mapLen))
-		mt.RawSetString("__call", L.NewFunction(mapCall))
+		mt.RawSetString("__index", newFunction(L, mapIndex))
+		mt.RawSetString("__newindex", newFunction(L, mapNewIndex))
+		mt.RawSetString("__len", newFunction(L, mapLen))
+		mt.RawSetString("__call", newFunction(L, mapCall))
 
 		addMethods(L, config, vtype, methods, false)
 	case reflect.Slice:
 		mt = L.CreateTable(0, 8)
 
-		mt.RawSetString("__index", L.NewFunction(sliceIndex))
-		mt.RawSetString("__newindex", L.NewFunction(sliceNewIndex))
-		mt.RawSetString("__len", L.NewFunction(sliceLen))
-		mt.RawSetString("__call", L.NewFunction(sliceCall))
-		mt.RawSetString("__add", L.NewFunction(sliceAdd))
+		mt.RawSetString("__index", newFunction(L, sliceIndex))
+		mt.RawSetString("__newindex", newFunction(L, sliceNewIndex))
+		mt.RawSetString("__len", newFunction(L, sliceLen))
+		mt.RawSetString("__call", newFunction(L, sliceCall))
+		mt.RawSetString("__add", newFunction(L, sliceAdd))
 
 		addMethods(L, config, vtype, methods, false)
 	case reflect.Struct:
@@ -147,40 +229,117 @@ func getMetatable(L *lua.LState, vtype reflect.Type) *lua.LTable {
 		addFields(L, config, vtype, fields)
 		mt.RawSetString("fields", fields)
 
-		mt.RawSetString("__index", L.NewFunction(structIndex))
-		mt.RawSetString("__eq", L.NewFunction(structEq))
+		mt.RawSetString("__index", newFunction(L, structIndex))
+		mt.RawSetString("__eq", newFunction(L, structEq))
 
+		addMethods(L, config, vtype, methods, false)
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		mt = L.CreateTable(0, 16)
+
+		mt.RawSetString("__index", newFunction(L, basicIndex))
+		mt.RawSetString("__eq", newFunction(L, basicEq))
+		mt.RawSetString("__tostring", newFunction(L, basicTostring))
+
+		switch kind := vtype.Kind(); {
+		case kind == reflect.String:
+			mt.RawSetString("__lt", newFunction(L, basicLt))
+			mt.RawSetString("__le", newFunction(L, basicLe))
+			mt.RawSetString("__len", newFunction(L, basicLen))
+			mt.RawSetString("__concat", newFunction(L, basicConcat))
+		case isNumericKind(kind):
+			mt.RawSetString("__lt", newFunction(L, basicLt))
+			mt.RawSetString("__le", newFunction(L, basicLe))
+			mt.RawSetString("__add", newFunction(L, basicArithFunc("add")))
+			mt.RawSetString("__sub", newFunction(L, basicArithFunc("sub")))
+			mt.RawSetString("__mul", newFunction(L, basicArithFunc("mul")))
+			mt.RawSetString("__div", newFunction(L, basicArithFunc("div")))
+			mt.RawSetString("__mod", newFunction(L, basicArithFunc("mod")))
+			mt.RawSetString("__pow", newFunction(L, basicArithFunc("pow")))
+			mt.RawSetString("__unm", newFunction(L, basicUnm))
+		}
+
+		addMethods(L, config, vtype, methods, false)
+	case reflect.Complex64, reflect.Complex128:
+		mt = L.CreateTable(0, 11)
+
+		mt.RawSetString("__index", newFunction(L, basicIndex))
+		mt.RawSetString("__eq", newFunction(L, complexEq))
+		mt.RawSetString("__tostring", newFunction(L, basicTostring))
+		mt.RawSetString("__add", newFunction(L, complexArithFunc("add")))
+		mt.RawSetString("__sub", newFunction(L, complexArithFunc("sub")))
+		mt.RawSetString("__mul", newFunction(L, complexArithFunc("mul")))
+		mt.RawSetString("__div", newFunction(L, complexArithFunc("div")))
+		mt.RawSetString("__pow", newFunction(L, complexArithFunc("pow")))
+		mt.RawSetString("__unm", newFunction(L, complexUnm))
+
+		addComplexMethods(L, methods)
 		addMethods(L, config, vtype, methods, false)
 	case reflect.Ptr:
 		switch vtype.Elem().Kind() {
 		case reflect.Array:
 			mt = L.CreateTable(0, 10)
 
-			mt.RawSetString("__index", L.NewFunction(arrayPtrIndex))
-			mt.RawSetString("__newindex", L.NewFunction(arrayPtrNewIndex))
-			mt.RawSetString("__call", L.NewFunction(arrayCall)) // same as non-pointer
-			mt.RawSetString("__len", L.NewFunction(arrayLen))   // same as non-pointer
+			mt.RawSetString("__index", newFunction(L, arrayPtrIndex))
+			mt.RawSetString("__newindex", newFunction(L, arrayPtrNewIndex))
+			mt.RawSetString("__call", newFunction(L, arrayCall)) // same as non-pointer
+			mt.RawSetString("__len", newFunction(L, arrayLen))   // same as non-pointer
 		case reflect.Struct:
-			mt = L.CreateTable(0, 8)
+			mt = L.CreateTable(0, 9)
+
+			// shares the fields of the struct type
+			mt.RawSetString("fields", getMetatable(L, vtype.Elem()).RawGetString("fields"))
 
-			mt.RawSetString("__index", L.NewFunction(structPtrIndex))
-			mt.RawSetString("__newindex", L.NewFunction(structPtrNewIndex))
+			mt.RawSetString("__index", newFunction(L, structPtrIndex))
+			mt.RawSetString("__newindex", newFunction(L, structPtrNewIndex))
 		default:
 			mt = L.CreateTable(0, 7)
 
-			mt.RawSetString("__index", L.NewFunction(ptrIndex))
+			mt.RawSetString("__index", newFunction(L, ptrIndex))
 		}
 
-		mt.RawSetString("__eq", L.NewFunction(ptrEq))
-		mt.RawSetString("__pow", L.NewFunction(ptrPow))
-		mt.RawSetString("__unm", L.NewFunction(ptrUnm))
+		mt.RawSetString("__eq", newFunction(L, ptrEq))
+		mt.RawSetString("__pow", newFunction(L, ptrPow))
+		mt.RawSetString("__unm", newFunction(L, ptrUnm))
+
+		if isBigType(vtype) {
+			addBigMetamethods(L, mt, vtype)
+		}
 
 		addMethods(L, config, vtype, methods, true)
 	default:
 		panic("unexpected kind " + vtype.Kind().String())
 	}
 
-	mt.RawSetString("__tostring", L.NewFunction(tostring))
+	if vtype.Implements(refTypeError) {
+		addErrorMethods(L, methods)
+	}
+	if vtype == refTypeFuture {
+		addFutureMethods(L, methods)
+	}
+
+	if fields, ok := mt.RawGetString("fields").(*lua.LTable); ok {
+		addMembers(L, mt, fields, methods)
+
+		if b := getBinding(vtype); b != nil && bindingFields(config) {
+			if vtype.Kind() == reflect.Ptr {
+				if b.Get != nil {
+					mt.RawSetString("__index", newFunction(L, bindingIndex(b, structPtrIndex)))
+				}
+				if b.Set != nil {
+					mt.RawSetString("__newindex", newFunction(L, bindingNewIndex(b)))
+				}
+			} else if b.Get != nil {
+				mt.RawSetString("__index", newFunction(L, bindingIndex(b, structIndex)))
+			}
+		}
+	}
+
+	if mt.RawGetString("__tostring") == lua.LNil {
+		mt.RawSetString("__tostring", newFunction(L, tostring))
+	}
 	mt.RawSetString("__metatable", lua.LString("gopher-luar"))
 	mt.RawSetString("methods", methods)
 
@@ -196,8 +355,8 @@ func getTypeMetatable(L *lua.LState, t reflect.Type) *lua.LTable {
 	}
 
 	mt := L.CreateTable(0, 3)
-	mt.RawSetString("__call", L.NewFunction(typeCall))
-	mt.RawSetString("__eq", L.NewFunction(typeEq))
+	mt.RawSetString("__call", newFunction(L, typeCall))
+	mt.RawSetString("__eq", newFunction(L, typeEq))
 	mt.RawSetString("__metatable", lua.LString("gopher-luar"))
 
 	config.types = mt
@@ -1,6 +1,7 @@
 package luar
 
 import (
+	"errors"
 	"reflect"
 
 	"github.com/yuin/gopher-lua"
@@ -42,6 +43,13 @@ func chanCall(L *lua.LState) int {
 		if ref.Type().ChanDir()&reflect.RecvDir == 0 {
 			L.ArgError(1, "receive from send-only type "+ref.Type().String())
 		}
+		if s := GetConfig(L).scheduler; s != nil && s.canWait(L) {
+			recv := func() []reflect.Value {
+				value, ok := ref.Recv()
+				return []reflect.Value{value, reflect.ValueOf(ok)}
+			}
+			return s.wait(L, "receive from "+ref.Type().String(), recv, chanRecvResults)
+		}
 		value, ok := ref.Recv()
 		if ok {
 			L.Push(New(L, value.Interface()))
@@ -65,6 +73,13 @@ func chanCall(L *lua.LState) int {
 			L.ArgError(2, err.Error())
 		}
 
+		if s := GetConfig(L).scheduler; s != nil && s.canWait(L) {
+			send := func() []reflect.Value {
+				ref.Send(convertedValue)
+				return nil
+			}
+			return s.wait(L, "send to "+ref.Type().String(), send, chanSendResults)
+		}
 		ref.Send(convertedValue)
 		return 0
 
@@ -79,3 +94,20 @@ func chanUnm(L *lua.LState) int {
 	ref.Close()
 	return 0
 }
+
+func chanRecvResults(L *lua.LState, ret []reflect.Value, panicMsg string) ([]lua.LValue, error) {
+	if !ret[1].Bool() {
+		return []lua.LValue{lua.LNil, lua.LFalse}, nil
+	}
+	return []lua.LValue{New(L, ret[0].Interface()), lua.LTrue}, nil
+}
+
+// chanSendResults terminates the waiting coroutine if the send panicked (i.e.
+// the channel was closed), as the error cannot be raised in the coroutine
+// once it has yielded.
+func chanSendResults(L *lua.LState, ret []reflect.Value, panicMsg string) ([]lua.LValue, error) {
+	if panicMsg != "" {
+		return nil, errors.New(panicMsg)
+	}
+	return nil, nil
+}
@@ -0,0 +1,469 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// basicTypes maps the predeclared types luargen handles to their conversion
+// from a Lua value: "string", "bool", "int", "uint", or "float".
+var basicTypes = map[string]string{
+	"string":  "string",
+	"bool":    "bool",
+	"int":     "int",
+	"int8":    "int",
+	"int16":   "int",
+	"int32":   "int",
+	"int64":   "int",
+	"rune":    "int",
+	"uint":    "uint",
+	"uint8":   "uint",
+	"uint16":  "uint",
+	"uint32":  "uint",
+	"uint64":  "uint",
+	"byte":    "uint",
+	"float32": "float",
+	"float64": "float",
+}
+
+type field struct {
+	name  string
+	names []string
+	// the predeclared type of the field, or "" if the field is converted
+	// using luar.New
+	basic string
+}
+
+type method struct {
+	name       string
+	ptr        bool
+	params     []string
+	results    []string
+	luaNames   []string
+	structName string
+}
+
+type structType struct {
+	name    string
+	fields  []field
+	methods []method
+}
+
+// generate returns the source of the file named filename that registers the
+// bindings of types, declared in the package in dir. command is included in
+// the file's header.
+func generate(dir string, types []string, filename, command string) ([]byte, error) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(info os.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go") && info.Name() != filename
+	}, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	if len(pkgs) != 1 {
+		return nil, fmt.Errorf("expecting one package in %s, found %d", dir, len(pkgs))
+	}
+	var pkg *ast.Package
+	for _, p := range pkgs {
+		pkg = p
+	}
+
+	structs := make(map[string]*structType)
+	var files []string
+	for name := range pkg.Files {
+		files = append(files, name)
+	}
+	sort.Strings(files)
+
+	for _, name := range files {
+		for _, decl := range pkg.Files[name].Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				spec := spec.(*ast.TypeSpec)
+				if st, ok := spec.Type.(*ast.StructType); ok && spec.TypeParams == nil {
+					structs[spec.Name.Name] = collectStruct(spec.Name.Name, st)
+				}
+			}
+		}
+	}
+	for _, name := range files {
+		for _, decl := range pkg.Files[name].Decls {
+			if fn, ok := decl.(*ast.FuncDecl); ok {
+				if m, ok := collectMethod(fn); ok && structs[m.structName] != nil {
+					structs[m.structName].methods = append(structs[m.structName].methods, m)
+				}
+			}
+		}
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by \"%s\"; DO NOT EDIT.\n\n", command)
+	fmt.Fprintf(&b, "package %s\n\n", pkg.Name)
+	fmt.Fprintf(&b, "import (\n\t\"github.com/yuin/gopher-lua\"\n\tluar \"layeh.com/gopher-luar\"\n)\n\n")
+
+	fmt.Fprintf(&b, "func init() {\n")
+	for _, name := range types {
+		st := structs[name]
+		if st == nil {
+			return nil, fmt.Errorf("struct type %s not found in %s", name, dir)
+		}
+		st.excludeMethodNames()
+		fmt.Fprintf(&b, "\tluar.RegisterBinding((*%s)(nil), &luar.Binding{\n", name)
+		fmt.Fprintf(&b, "\t\tGet: luarGet%s,\n", name)
+		fmt.Fprintf(&b, "\t\tSet: luarSet%s,\n", name)
+		if len(st.methods) > 0 {
+			fmt.Fprintf(&b, "\t\tMethods: map[string]func(L *lua.LState) int{\n")
+			for _, m := range st.methods {
+				fmt.Fprintf(&b, "\t\t\t%q: luar%s%s,\n", m.name, name, m.name)
+			}
+			fmt.Fprintf(&b, "\t\t},\n")
+		}
+		fmt.Fprintf(&b, "\t})\n")
+	}
+	fmt.Fprintf(&b, "}\n")
+
+	for _, name := range types {
+		structs[name].write(&b)
+	}
+
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %v", err)
+	}
+	return src, nil
+}
+
+func collectStruct(name string, st *ast.StructType) *structType {
+	s := &structType{name: name}
+	for _, f := range st.Fields.List {
+		if len(f.Names) == 0 {
+			// embedded fields and the fields they promote are accessed
+			// using reflection
+			continue
+		}
+		basic, ok := fieldType(f.Type)
+		if !ok {
+			continue
+		}
+		var tag string
+		if f.Tag != nil {
+			if unquoted, err := strconv.Unquote(f.Tag.Value); err == nil {
+				tag = reflect.StructTag(unquoted).Get("luar")
+			}
+		}
+		for _, ident := range f.Names {
+			if !ident.IsExported() {
+				continue
+			}
+			names := fieldNames(ident.Name, tag)
+			if len(names) == 0 {
+				continue
+			}
+			s.fields = append(s.fields, field{
+				name:  ident.Name,
+				names: names,
+				basic: basic,
+			})
+		}
+	}
+	return s
+}
+
+// fieldType returns the name of expr if it is a predeclared type handled by
+// luargen, or "" if it is a type converted using luar.New. ok is false if
+// luargen does not handle the type.
+func fieldType(expr ast.Expr) (basic string, ok bool) {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		if _, ok := basicTypes[t.Name]; ok {
+			return t.Name, true
+		}
+	case *ast.StarExpr, *ast.MapType, *ast.ChanType, *ast.FuncType, *ast.InterfaceType:
+		return "", true
+	case *ast.ArrayType:
+		// slices, not arrays
+		return "", t.Len == nil
+	}
+	return "", false
+}
+
+// fieldNames returns the names of a field, following luar's default
+// Config.FieldNames behaviour.
+func fieldNames(name, tag string) []string {
+	if i := strings.IndexByte(tag, ','); i >= 0 {
+		// options are not part of the name, and the extra field (see
+		// luar.UnknownKeysCollect) has no name
+		for _, option := range strings.Split(tag[i+1:], ",") {
+			if option == "extra" {
+				return nil
+			}
+		}
+		tag = tag[:i]
+	}
+	if tag == "-" {
+		return nil
+	}
+	if tag != "" {
+		return []string{tag}
+	}
+	return uniqueNames(name, lowerFirst(name))
+}
+
+func uniqueNames(name, lower string) []string {
+	if lower == name {
+		return []string{name}
+	}
+	return []string{name, lower}
+}
+
+func lowerFirst(name string) string {
+	first, n := utf8.DecodeRuneInString(name)
+	if n == 0 {
+		return name
+	}
+	return string(unicode.ToLower(first)) + name[n:]
+}
+
+func collectMethod(fn *ast.FuncDecl) (method, bool) {
+	if fn.Recv == nil || len(fn.Recv.List) != 1 || !fn.Name.IsExported() {
+		return method{}, false
+	}
+	m := method{name: fn.Name.Name}
+
+	recv := fn.Recv.List[0].Type
+	if star, ok := recv.(*ast.StarExpr); ok {
+		m.ptr = true
+		recv = star.X
+	}
+	ident, ok := recv.(*ast.Ident)
+	if !ok {
+		// generic receivers are not supported
+		return method{}, false
+	}
+	m.structName = ident.Name
+
+	for _, list := range []struct {
+		fields *ast.FieldList
+		types  *[]string
+	}{
+		{fn.Type.Params, &m.params},
+		{fn.Type.Results, &m.results},
+	} {
+		if list.fields == nil {
+			continue
+		}
+		for _, f := range list.fields.List {
+			ident, ok := f.Type.(*ast.Ident)
+			if !ok {
+				return method{}, false
+			}
+			if _, ok := basicTypes[ident.Name]; !ok {
+				return method{}, false
+			}
+			n := len(f.Names)
+			if n == 0 {
+				n = 1
+			}
+			for i := 0; i < n; i++ {
+				*list.types = append(*list.types, ident.Name)
+			}
+		}
+	}
+	m.luaNames = uniqueNames(m.name, lowerFirst(m.name))
+	return m, true
+}
+
+// excludeMethodNames removes the names of fields that are also the default
+// names of methods, as luar looks up methods before fields. Only the methods
+// luargen generates are known here; luar also skips the generated Get for the
+// names of other methods (e.g. promoted ones, or with non-basic parameters).
+func (s *structType) excludeMethodNames() {
+	methodNames := make(map[string]bool)
+	for _, m := range s.methods {
+		for _, name := range m.luaNames {
+			methodNames[name] = true
+		}
+	}
+
+	fields := s.fields[:0]
+	for _, f := range s.fields {
+		var names []string
+		for _, name := range f.names {
+			if !methodNames[name] {
+				names = append(names, name)
+			}
+		}
+		if len(names) > 0 {
+			f.names = names
+			fields = append(fields, f)
+		}
+	}
+	s.fields = fields
+}
+
+func quoteNames(names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = strconv.Quote(name)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// pushExpr returns the expression that converts expr, of the predeclared
+// type typ, to a Lua value.
+func pushExpr(typ, expr string) string {
+	switch basicTypes[typ] {
+	case "string":
+		return "lua.LString(" + expr + ")"
+	case "bool":
+		return "lua.LBool(" + expr + ")"
+	}
+	return "lua.LNumber(" + expr + ")"
+}
+
+// luaType returns the Lua type that is converted to the predeclared type typ.
+func luaType(typ string) string {
+	switch basicTypes[typ] {
+	case "string":
+		return "lua.LString"
+	case "bool":
+		return "lua.LBool"
+	}
+	return "lua.LNumber"
+}
+
+// convertExpr returns the expression that converts expr, a value of the Lua
+// type returned by luaType, to the predeclared type typ.
+func convertExpr(typ, expr string) string {
+	return typ + "(" + expr + ")"
+}
+
+// lossExpr returns a condition that is true if converting expr using
+// convertExpr would lose part of its value (e.g. its fractional part), or ""
+// if no value is lost. Such values are converted by luar instead, according
+// to Config.Conversion.
+func lossExpr(typ, expr string) string {
+	if bounds, ok := integerBounds[typ]; ok {
+		// the range is checked first, as converting an out of range float
+		// to an integer is implementation-defined. int and uint are
+		// converted through their 64-bit types, whose range includes them.
+		convert := typ + "(" + expr + ")"
+		switch typ {
+		case "int":
+			convert = "int(int64(" + expr + "))"
+		case "uint":
+			convert = "uint(uint64(" + expr + "))"
+		}
+		return "!(" + expr + " >= " + bounds[0] + " && " + expr + " < " + bounds[1] + ") || lua.LNumber(" + convert + ") != " + expr
+	}
+	if typ == "float32" {
+		// math.MaxFloat32
+		return expr + " > 3.4028234663852886e+38 || " + expr + " < -3.4028234663852886e+38"
+	}
+	return ""
+}
+
+// integerBounds maps the integer types in basicTypes to the lower (inclusive)
+// and upper (exclusive) bounds of their range.
+var integerBounds = map[string][2]string{
+	"int":    {"-9223372036854775808", "9223372036854775808"},
+	"int8":   {"-128", "128"},
+	"int16":  {"-32768", "32768"},
+	"int32":  {"-2147483648", "2147483648"},
+	"int64":  {"-9223372036854775808", "9223372036854775808"},
+	"rune":   {"-2147483648", "2147483648"},
+	"uint":   {"0", "18446744073709551616"},
+	"uint8":  {"0", "256"},
+	"uint16": {"0", "65536"},
+	"uint32": {"0", "4294967296"},
+	"uint64": {"0", "18446744073709551616"},
+	"byte":   {"0", "256"},
+}
+
+// assertExpr returns the statements that assert that expr is of the Lua type
+// returned by luaType for typ, storing it in name, and that it can be
+// converted using convertExpr. fail is executed otherwise.
+func assertExpr(typ, name, expr, fail string) string {
+	cond := "!ok"
+	if loss := lossExpr(typ, name); loss != "" {
+		cond += " || " + loss
+	}
+	return fmt.Sprintf("%s, ok := %s.(%s)\nif %s {\n%s\n}\n", name, expr, luaType(typ), cond, fail)
+}
+
+func (s *structType) write(b *bytes.Buffer) {
+	fmt.Fprintf(b, "\nfunc luarGet%s(L *lua.LState, value interface{}, key string) bool {\n", s.name)
+	fmt.Fprintf(b, "var v *%s\n", s.name)
+	fmt.Fprintf(b, "switch value := value.(type) {\ncase *%s:\nv = value\ncase %s:\nv = &value\n}\n", s.name, s.name)
+	fmt.Fprintf(b, "if v == nil {\nreturn false\n}\n")
+	fmt.Fprintf(b, "switch key {\n")
+	for _, f := range s.fields {
+		fmt.Fprintf(b, "case %s:\n", quoteNames(f.names))
+		if f.basic != "" {
+			fmt.Fprintf(b, "L.Push(%s)\n", pushExpr(f.basic, "v."+f.name))
+		} else {
+			fmt.Fprintf(b, "L.Push(luar.New(L, v.%s))\n", f.name)
+		}
+	}
+	fmt.Fprintf(b, "default:\nreturn false\n}\nreturn true\n}\n")
+
+	fmt.Fprintf(b, "\nfunc luarSet%s(L *lua.LState, value interface{}, key string, lv lua.LValue) bool {\n", s.name)
+	fmt.Fprintf(b, "v, ok := value.(*%s)\nif !ok || v == nil {\nreturn false\n}\n", s.name)
+	fmt.Fprintf(b, "switch key {\n")
+	for _, f := range s.fields {
+		if f.basic == "" {
+			continue
+		}
+		fmt.Fprintf(b, "case %s:\n", quoteNames(f.names))
+		b.WriteString(assertExpr(f.basic, "x", "lv", "return false"))
+		fmt.Fprintf(b, "v.%s = %s\n", f.name, convertExpr(f.basic, "x"))
+	}
+	fmt.Fprintf(b, "default:\nreturn false\n}\nreturn true\n}\n")
+
+	for _, m := range s.methods {
+		fmt.Fprintf(b, "\nfunc luar%s%s(L *lua.LState) int {\n", s.name, m.name)
+		fmt.Fprintf(b, "if L.GetTop() != %d {\nreturn -1\n}\n", len(m.params)+1)
+		fmt.Fprintf(b, "ud, ok := L.Get(1).(*lua.LUserData)\nif !ok {\nreturn -1\n}\n")
+		fmt.Fprintf(b, "var recv *%s\n", s.name)
+		if m.ptr {
+			fmt.Fprintf(b, "if recv, ok = ud.Value.(*%s); !ok || recv == nil {\nreturn -1\n}\n", s.name)
+		} else {
+			fmt.Fprintf(b, "switch value := ud.Value.(type) {\ncase *%s:\nrecv = value\ncase %s:\nrecv = &value\n}\n", s.name, s.name)
+			fmt.Fprintf(b, "if recv == nil {\nreturn -1\n}\n")
+		}
+		args := make([]string, len(m.params))
+		for i, param := range m.params {
+			arg := fmt.Sprintf("a%d", i)
+			b.WriteString(assertExpr(param, arg, fmt.Sprintf("L.Get(%d)", i+2), "return -1"))
+			args[i] = convertExpr(param, arg)
+		}
+		call := "recv." + m.name + "(" + strings.Join(args, ", ") + ")"
+		if len(m.results) == 0 {
+			fmt.Fprintf(b, "%s\nreturn 0\n}\n", call)
+			continue
+		}
+		results := make([]string, len(m.results))
+		for i := range m.results {
+			results[i] = fmt.Sprintf("r%d", i)
+		}
+		fmt.Fprintf(b, "%s := %s\n", strings.Join(results, ", "), call)
+		for i, result := range m.results {
+			fmt.Fprintf(b, "L.Push(%s)\n", pushExpr(result, results[i]))
+		}
+		fmt.Fprintf(b, "return %d\n}\n", len(m.results))
+	}
+}
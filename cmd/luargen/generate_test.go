@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	dir := filepath.Join("internal", "example")
+	expected, err := ioutil.ReadFile(filepath.Join(dir, "person_luar.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src, err := generate(dir, []string{"Person"}, "person_luar.go", "luargen -type Person")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(src, expected) {
+		t.Fatalf("generated code does not match person_luar.go; run go generate:\n%s", src)
+	}
+}
+
+func TestGenerateMissingType(t *testing.T) {
+	if _, err := generate(filepath.Join("internal", "example"), []string{"Missing"}, "missing_luar.go", "luargen"); err == nil {
+		t.Fatal("expecting error")
+	}
+}
+
+func TestLossExpr(t *testing.T) {
+	for _, test := range []struct {
+		typ, expected string
+	}{
+		{"int8", "!(x >= -128 && x < 128) || lua.LNumber(int8(x)) != x"},
+		{"uint", "!(x >= 0 && x < 18446744073709551616) || lua.LNumber(uint(uint64(x))) != x"},
+		{"float32", "x > 3.4028234663852886e+38 || x < -3.4028234663852886e+38"},
+		{"float64", ""},
+		{"string", ""},
+	} {
+		if expr := lossExpr(test.typ, "x"); expr != test.expected {
+			t.Fatalf("%s: expecting %q, got %q", test.typ, test.expected, expr)
+		}
+	}
+}
+
+func TestFieldNames(t *testing.T) {
+	for _, test := range []struct {
+		name, tag string
+		expected  []string
+	}{
+		{"Name", "", []string{"Name", "name"}},
+		{"Name", "-", nil},
+		{"Name", "custom", []string{"custom"}},
+		{"Name", "custom,other", []string{"custom"}},
+		{"Name", "custom,extra", nil},
+		{"Extra", ",extra", nil},
+	} {
+		names := fieldNames(test.name, test.tag)
+		if len(names) != len(test.expected) {
+			t.Fatalf("%s %q: expecting %v, got %v", test.name, test.tag, test.expected, names)
+		}
+		for i := range names {
+			if names[i] != test.expected[i] {
+				t.Fatalf("%s %q: expecting %v, got %v", test.name, test.tag, test.expected, names)
+			}
+		}
+	}
+}
@@ -0,0 +1,46 @@
+// Package example holds types used to test the bindings generated by
+// luargen.
+package example
+
+//go:generate go run layeh.com/gopher-luar/cmd/luargen -type Person
+
+import (
+	"strings"
+)
+
+type Address struct {
+	City string
+}
+
+type Person struct {
+	Name     string
+	Age      int
+	Score    float64
+	Admin    bool   `luar:"is_admin"`
+	Secret   string `luar:"-"`
+	Tags     []string
+	Friend   *Person
+	Home     Address
+	Nickname string
+	Summary  string `luar:"describe"`
+	Address
+
+	private int
+}
+
+func (p Person) Greet(greeting string, times int) string {
+	return strings.Repeat(greeting+" "+p.Name+"! ", times)
+}
+
+func (p *Person) Birthday() int {
+	p.Age++
+	return p.Age
+}
+
+func (p *Person) SetFriend(friend *Person) {
+	p.Friend = friend
+}
+
+func (p *Person) Describe(friend *Person) string {
+	return p.Name + " and " + friend.Name
+}
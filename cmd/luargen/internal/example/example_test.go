@@ -0,0 +1,148 @@
+package example
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/yuin/gopher-lua"
+	luar "layeh.com/gopher-luar"
+)
+
+const script = `
+	local results = {}
+	local function try(fn)
+		local ok, value = pcall(fn)
+		if ok then
+			results[#results + 1] = tostring(value)
+		else
+			results[#results + 1] = "error"
+		end
+	end
+
+	try(function() return p.Name .. p.name end)
+	try(function() p.age = 41.9 return p.Age end)
+	try(function() p.Score = 1.5 return p.score end)
+	try(function() p.is_admin = true return p.is_admin end)
+	try(function() return p.Admin end)
+	try(function() return p.Secret end)
+	try(function() return p.Tags[2] end)
+	try(function() p.Friend = p return p.friend.Name end)
+	try(function() return p.Home.City end)
+	try(function() return p.City end)
+	try(function() p.City = "Paris" return p.city end)
+	try(function() p.Age = "x" end)
+	try(function() p.Unknown = 1 end)
+	try(function() return p:Greet("Hi", 2) end)
+	try(function() return p:greet("Hi") end)
+	try(function() return p:greet("Hi", "x") end)
+	try(function() return p:Birthday() end)
+	try(function() return v:Greet("Hello", 1) end)
+	try(function() return v.Name end)
+	try(function() return p:describe(p) end)
+	return results
+`
+
+func run(t *testing.T, fieldNames func(s reflect.Type, f reflect.StructField) []string) []string {
+	L := lua.NewState()
+	defer L.Close()
+	luar.GetConfig(L).FieldNames = fieldNames
+
+	p := &Person{
+		Name:   "Bob",
+		Age:    40,
+		Secret: "x",
+		Tags:   []string{"a", "b"},
+		Home:   Address{City: "Rome"},
+	}
+	L.SetGlobal("p", luar.New(L, p))
+	L.SetGlobal("v", luar.New(L, *p))
+
+	if err := L.DoString(script); err != nil {
+		t.Fatal(err)
+	}
+	var results []string
+	L.Get(-1).(*lua.LTable).ForEach(func(_, value lua.LValue) {
+		results = append(results, value.String())
+	})
+	return results
+}
+
+// defaultFieldNames mirrors luar's default field names. Setting it disables
+// the generated field accessors.
+func defaultFieldNames(s reflect.Type, f reflect.StructField) []string {
+	tag := f.Tag.Get("luar")
+	if tag == "-" {
+		return nil
+	}
+	if tag != "" {
+		return []string{tag}
+	}
+	return []string{f.Name, string(f.Name[0]+'a'-'A') + f.Name[1:]}
+}
+
+func TestBinding(t *testing.T) {
+	generated := run(t, nil)
+	reflected := run(t, defaultFieldNames)
+
+	expected := []string{
+		"BobBob", "41", "1.5", "true", "nil", "nil", "b", "Bob", "Rome", "",
+		"Paris", "error", "error", "Hi Bob! Hi Bob! ", "error", "error", "42",
+		"Hello Bob! ", "Bob", "Bob and Bob",
+	}
+	if !reflect.DeepEqual(generated, expected) {
+		t.Fatalf("expecting %#v, got %#v", expected, generated)
+	}
+	if !reflect.DeepEqual(generated, reflected) {
+		t.Fatalf("generated bindings differ from reflection: %#v, %#v", generated, reflected)
+	}
+}
+
+func TestBindingConversion(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+	luar.GetConfig(L).Conversion = luar.ConvertStrict
+
+	p := &Person{}
+	L.SetGlobal("p", luar.New(L, p))
+
+	// values the generated code cannot convert exactly are converted by luar
+	if err := L.DoString(`p.Age = 41`); err != nil || p.Age != 41 {
+		t.Fatalf("unexpected age %d (%v)", p.Age, err)
+	}
+	if err := L.DoString(`p.Age = 41.9`); err == nil || !strings.Contains(err.Error(), "fractional part 0.9 would be lost") {
+		t.Fatalf("expecting fractional part error, got %v", err)
+	}
+	if err := L.DoString(`p:Greet("Hi", 1.5)`); err == nil || !strings.Contains(err.Error(), "fractional part 0.5 would be lost") {
+		t.Fatalf("expecting fractional part error, got %v", err)
+	}
+}
+
+func BenchmarkBinding(b *testing.B) {
+	for _, bench := range []struct {
+		name       string
+		fieldNames func(s reflect.Type, f reflect.StructField) []string
+	}{
+		{"generated", nil},
+		{"reflection", defaultFieldNames},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			L := lua.NewState()
+			defer L.Close()
+			luar.GetConfig(L).FieldNames = bench.fieldNames
+			L.SetGlobal("p", luar.New(L, &Person{Name: "Bob"}))
+
+			fn, err := L.LoadString(`local n = ... for i = 1, n do p.Age = i; local _ = p.Tags; p:Greet("Hi", 1) end`)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			L.Push(fn)
+			L.Push(lua.LNumber(b.N))
+			if err := L.PCall(1, 0, nil); err != nil {
+				b.Fatal(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+// Code generated by "luargen -type Person"; DO NOT EDIT.
+
+package example
+
+import (
+	"github.com/yuin/gopher-lua"
+	luar "layeh.com/gopher-luar"
+)
+
+func init() {
+	luar.RegisterBinding((*Person)(nil), &luar.Binding{
+		Get: luarGetPerson,
+		Set: luarSetPerson,
+		Methods: map[string]func(L *lua.LState) int{
+			"Greet":    luarPersonGreet,
+			"Birthday": luarPersonBirthday,
+		},
+	})
+}
+
+func luarGetPerson(L *lua.LState, value interface{}, key string) bool {
+	var v *Person
+	switch value := value.(type) {
+	case *Person:
+		v = value
+	case Person:
+		v = &value
+	}
+	if v == nil {
+		return false
+	}
+	switch key {
+	case "Name", "name":
+		L.Push(lua.LString(v.Name))
+	case "Age", "age":
+		L.Push(lua.LNumber(v.Age))
+	case "Score", "score":
+		L.Push(lua.LNumber(v.Score))
+	case "is_admin":
+		L.Push(lua.LBool(v.Admin))
+	case "Tags", "tags":
+		L.Push(luar.New(L, v.Tags))
+	case "Friend", "friend":
+		L.Push(luar.New(L, v.Friend))
+	case "Nickname", "nickname":
+		L.Push(lua.LString(v.Nickname))
+	case "describe":
+		L.Push(lua.LString(v.Summary))
+	default:
+		return false
+	}
+	return true
+}
+
+func luarSetPerson(L *lua.LState, value interface{}, key string, lv lua.LValue) bool {
+	v, ok := value.(*Person)
+	if !ok || v == nil {
+		return false
+	}
+	switch key {
+	case "Name", "name":
+		x, ok := lv.(lua.LString)
+		if !ok {
+			return false
+		}
+		v.Name = string(x)
+	case "Age", "age":
+		x, ok := lv.(lua.LNumber)
+		if !ok || !(x >= -9223372036854775808 && x < 9223372036854775808) || lua.LNumber(int(int64(x))) != x {
+			return false
+		}
+		v.Age = int(x)
+	case "Score", "score":
+		x, ok := lv.(lua.LNumber)
+		if !ok {
+			return false
+		}
+		v.Score = float64(x)
+	case "is_admin":
+		x, ok := lv.(lua.LBool)
+		if !ok {
+			return false
+		}
+		v.Admin = bool(x)
+	case "Nickname", "nickname":
+		x, ok := lv.(lua.LString)
+		if !ok {
+			return false
+		}
+		v.Nickname = string(x)
+	case "describe":
+		x, ok := lv.(lua.LString)
+		if !ok {
+			return false
+		}
+		v.Summary = string(x)
+	default:
+		return false
+	}
+	return true
+}
+
+func luarPersonGreet(L *lua.LState) int {
+	if L.GetTop() != 3 {
+		return -1
+	}
+	ud, ok := L.Get(1).(*lua.LUserData)
+	if !ok {
+		return -1
+	}
+	var recv *Person
+	switch value := ud.Value.(type) {
+	case *Person:
+		recv = value
+	case Person:
+		recv = &value
+	}
+	if recv == nil {
+		return -1
+	}
+	a0, ok := L.Get(2).(lua.LString)
+	if !ok {
+		return -1
+	}
+	a1, ok := L.Get(3).(lua.LNumber)
+	if !ok || !(a1 >= -9223372036854775808 && a1 < 9223372036854775808) || lua.LNumber(int(int64(a1))) != a1 {
+		return -1
+	}
+	r0 := recv.Greet(string(a0), int(a1))
+	L.Push(lua.LString(r0))
+	return 1
+}
+
+func luarPersonBirthday(L *lua.LState) int {
+	if L.GetTop() != 1 {
+		return -1
+	}
+	ud, ok := L.Get(1).(*lua.LUserData)
+	if !ok {
+		return -1
+	}
+	var recv *Person
+	if recv, ok = ud.Value.(*Person); !ok || recv == nil {
+		return -1
+	}
+	r0 := recv.Birthday()
+	L.Push(lua.LNumber(r0))
+	return 1
+}
@@ -0,0 +1,71 @@
+// Command luargen generates luar bindings for Go struct types, which allow
+// their fields and methods to be accessed from Lua without reflection.
+//
+// Usage:
+//
+//	luargen -type T[,T...] [-output file] [directory]
+//
+// luargen reads the Go package in directory (the current directory by
+// default), and writes a file that registers a luar.Binding for each listed
+// type in its init function. It is typically invoked by go generate:
+//
+//	//go:generate luargen -type Person
+//
+// The generated code handles exported fields declared directly in the
+// struct whose type is a predeclared bool, numeric, or string type, a
+// pointer, slice, map, channel, function, or interface. It also handles
+// exported methods declared in the package whose parameters and results
+// are predeclared bool, numeric, or string types. Other fields and methods,
+// including promoted ones, are accessed using reflection, as are all fields
+// in states with a custom Config.FieldNames.
+//
+// Field names follow the default behaviour of Config.FieldNames, including
+// the "luar" struct tag. Method names are chosen by luar at run time, so a
+// custom Config.MethodNames is respected.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("luargen: ")
+
+	typeNames := flag.String("type", "", "comma-separated list of struct type names; required")
+	output := flag.String("output", "", "output file name; default <dir>/<type>_luar.go")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: luargen -type T[,T...] [-output file] [directory]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *typeNames == "" || flag.NArg() > 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	types := strings.Split(*typeNames, ",")
+
+	dir := "."
+	if flag.NArg() == 1 {
+		dir = flag.Arg(0)
+	}
+	name := *output
+	if name == "" {
+		name = filepath.Join(dir, strings.ToLower(types[0])+"_luar.go")
+	}
+
+	src, err := generate(dir, types, filepath.Base(name), "luargen "+strings.Join(os.Args[1:], " "))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := ioutil.WriteFile(name, src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
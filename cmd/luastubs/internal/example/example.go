@@ -0,0 +1,43 @@
+// Package example holds types and functions used to test the stubs written
+// by luastubs.
+package example
+
+//go:generate go run layeh.com/gopher-luar/cmd/luastubs -type Item,Shop=Cart -value NewCart,Default -output example.lua
+
+import (
+	"strings"
+)
+
+type Kind int
+
+type Item struct {
+	Name  string
+	Price float64 `luar:"cost"`
+	Kind  Kind
+	Tags  []string
+	note  string
+}
+
+func (i Item) Describe() string {
+	return i.Name + " (" + strings.Join(i.Tags, ", ") + ")"
+}
+
+func (i *Item) SetPrice(price float64) {
+	i.Price = price
+}
+
+type Cart []*Item
+
+func (c Cart) Total() float64 {
+	var total float64
+	for _, item := range c {
+		total += item.Price
+	}
+	return total
+}
+
+func NewCart(items ...*Item) Cart {
+	return Cart(items)
+}
+
+var Default = &Item{Name: "default"}
@@ -0,0 +1,67 @@
+---@meta
+
+---@type example.ItemPtr
+Default = nil
+
+---@type fun(): example.ItemPtr
+Item = nil
+
+---@param ... example.ItemPtr|table
+---@return example.Cart
+function NewCart(...) end
+
+---@type fun(length?: integer, capacity?: integer): example.Cart
+Shop = nil
+
+---@class example.ItemPtr
+---@field Kind integer
+---@field Name string
+---@field Tags string[]
+---@field cost number
+---@field kind integer
+---@field name string
+---@field tags string[]
+---@operator pow(example.Item|table): example.ItemPtr
+---@operator unm: example.Item
+local example_ItemPtr = {}
+
+---@return string
+function example_ItemPtr:Describe() end
+
+---@param p1 number
+function example_ItemPtr:SetPrice(p1) end
+
+---@return string
+function example_ItemPtr:describe() end
+
+---@param p1 number
+function example_ItemPtr:setPrice(p1) end
+
+---@class example.Cart
+---@field [integer] example.ItemPtr
+---@operator add(example.ItemPtr|table): example.Cart
+---@operator len: integer
+---@operator call: fun(): integer, example.ItemPtr
+local example_Cart = {}
+
+---@return number
+function example_Cart:Total() end
+
+---@return number
+function example_Cart:total() end
+
+---@class example.Item
+---@field Kind integer
+---@field Name string
+---@field Tags string[]
+---@field cost number
+---@field kind integer
+---@field name string
+---@field tags string[]
+local example_Item = {}
+
+---@return string
+function example_Item:Describe() end
+
+---@return string
+function example_Item:describe() end
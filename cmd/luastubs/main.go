@@ -0,0 +1,103 @@
+// Command luastubs writes a definition file for the Lua language server
+// (LuaLS) describing Go types, functions, and variables as luar exposes them
+// to Lua, so that editors can offer completion for them.
+//
+// Usage:
+//
+//	luastubs [-type T[,T...]] [-value V[,V...]] [-output file] [package]
+//
+// Each listed type T is described as the type generator returned by
+// luar.NewType, and each listed value V (a function or variable) as the
+// value returned by luar.New, under the global name of the same name. A
+// different global name can be chosen with name=T or name=V. The package
+// (the current directory by default) must be part of a module that requires
+// layeh.com/gopher-luar.
+//
+// luastubs builds and runs a small program that calls luar.WriteStubs with
+// the default Config. The program is written to a temporary directory within
+// the package's directory, which is removed once it has run. States with a
+// custom Config (e.g. Config.FieldNames) should call luar.WriteStubs
+// directly.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("luastubs: ")
+
+	typeNames := flag.String("type", "", "comma-separated list of type names")
+	valueNames := flag.String("value", "", "comma-separated list of function and variable names")
+	output := flag.String("output", "", "output file name; default standard output")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: luastubs [-type T[,T...]] [-value V[,V...]] [-output file] [package]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if (*typeNames == "" && *valueNames == "") || flag.NArg() > 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	pkg := "."
+	if flag.NArg() == 1 {
+		pkg = flag.Arg(0)
+	}
+
+	globals, err := parseGlobals(*typeNames, *valueNames)
+	if err != nil {
+		log.Fatal(err)
+	}
+	stubs, err := run(pkg, globals)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *output == "" {
+		os.Stdout.Write(stubs)
+		return
+	}
+	if err := ioutil.WriteFile(*output, stubs, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// parseGlobals parses the -type and -value flags.
+func parseGlobals(typeNames, valueNames string) ([]global, error) {
+	var globals []global
+	for _, list := range []struct {
+		names  string
+		isType bool
+	}{
+		{typeNames, true},
+		{valueNames, false},
+	} {
+		if list.names == "" {
+			continue
+		}
+		for _, spec := range strings.Split(list.names, ",") {
+			g := global{isType: list.isType}
+			if i := strings.IndexByte(spec, '='); i >= 0 {
+				g.lua, g.goName = spec[:i], spec[i+1:]
+			} else {
+				g.lua, g.goName = spec, spec
+			}
+			if g.lua == "" || !isExported(g.goName) {
+				return nil, fmt.Errorf("invalid name %q", spec)
+			}
+			globals = append(globals, g)
+		}
+	}
+	return globals, nil
+}
+
+func isExported(name string) bool {
+	return name != "" && name[0] >= 'A' && name[0] <= 'Z' && !strings.ContainsAny(name, ". \t")
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseGlobals(t *testing.T) {
+	globals, err := parseGlobals("Item,Shop=Cart", "NewCart")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []global{
+		{lua: "Item", goName: "Item", isType: true},
+		{lua: "Shop", goName: "Cart", isType: true},
+		{lua: "NewCart", goName: "NewCart"},
+	}
+	if !reflect.DeepEqual(globals, expected) {
+		t.Fatalf("expecting %v, got %v", expected, globals)
+	}
+
+	for _, invalid := range []string{"item", "=Item", "x=", "pkg.Item"} {
+		if _, err := parseGlobals(invalid, ""); err == nil {
+			t.Fatalf("%s: expecting error", invalid)
+		}
+	}
+}
+
+func TestProgram(t *testing.T) {
+	src, err := program("example.com/pkg", []global{
+		{lua: "Shop", goName: "Cart", isType: true},
+		{lua: "NewCart", goName: "NewCart"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		`pkg "example.com/pkg"`,
+		`"Shop":    reflect.TypeOf((*pkg.Cart)(nil)).Elem(),`,
+		`"NewCart": pkg.NewCart,`,
+		`luar.WriteStubs(os.Stdout, L, globals)`,
+	} {
+		if !strings.Contains(string(src), expected) {
+			t.Fatalf("expecting program to contain %q:\n%s", expected, src)
+		}
+	}
+
+	src, err = program("example.com/pkg", []global{{lua: "f", goName: "F"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(src), `"reflect"`) {
+		t.Fatalf("unexpected reflect import:\n%s", src)
+	}
+}
+
+func TestRun(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds a program")
+	}
+
+	dir := filepath.Join("internal", "example")
+	expected, err := ioutil.ReadFile(filepath.Join(dir, "example.lua"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	globals, err := parseGlobals("Item,Shop=Cart", "NewCart,Default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stubs, err := run("./"+filepath.ToSlash(dir), globals)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(stubs, expected) {
+		t.Fatalf("stubs do not match example.lua; run go generate:\n%s", stubs)
+	}
+}
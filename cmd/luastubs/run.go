@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// global is a Go type or value described as a Lua global.
+type global struct {
+	lua    string
+	goName string
+	isType bool
+}
+
+// program returns the source of a program that writes the stubs of globals,
+// declared in the package with the given import path, to its standard
+// output.
+func program(importPath string, globals []global) ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteString(`// Code generated by luastubs. DO NOT EDIT.
+
+package main
+
+import (
+	"fmt"
+	"os"
+`)
+	for _, g := range globals {
+		if g.isType {
+			b.WriteString("\t\"reflect\"\n")
+			break
+		}
+	}
+	fmt.Fprintf(&b, `
+	"github.com/yuin/gopher-lua"
+	luar "layeh.com/gopher-luar"
+
+	pkg %s
+)
+
+func main() {`, strconv.Quote(importPath))
+	b.WriteString(`
+	L := lua.NewState()
+	defer L.Close()
+
+	globals := map[string]interface{}{
+`)
+	for _, g := range globals {
+		if g.isType {
+			fmt.Fprintf(&b, "%s: reflect.TypeOf((*pkg.%s)(nil)).Elem(),\n", strconv.Quote(g.lua), g.goName)
+		} else {
+			fmt.Fprintf(&b, "%s: pkg.%s,\n", strconv.Quote(g.lua), g.goName)
+		}
+	}
+	b.WriteString(`}
+	if err := luar.WriteStubs(os.Stdout, L, globals); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+`)
+	return format.Source(b.Bytes())
+}
+
+// run builds and runs the program that writes the stubs of globals, declared
+// in pkg, and returns its output.
+func run(pkg string, globals []global) ([]byte, error) {
+	list := exec.Command("go", "list", "-f", "{{.ImportPath}}\n{{.Dir}}", pkg)
+	list.Stderr = os.Stderr
+	out, err := list.Output()
+	if err != nil {
+		return nil, fmt.Errorf("go list %s: %v", pkg, err)
+	}
+	lines := strings.SplitN(strings.TrimSpace(string(out)), "\n", 2)
+	if len(lines) != 2 {
+		return nil, fmt.Errorf("go list %s: unexpected output %q", pkg, out)
+	}
+	importPath, pkgDir := lines[0], lines[1]
+
+	src, err := program(importPath, globals)
+	if err != nil {
+		return nil, err
+	}
+
+	// the program is built in a temporary directory within pkg's directory,
+	// so that it uses the same module (and versions of pkg's dependencies),
+	// and may import pkg if it is internal. Directories starting with "_"
+	// are ignored by patterns such as ./..., so concurrent builds of the
+	// module do not see it.
+	dir, err := ioutil.TempDir(pkgDir, "_luastubs")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "main.go"), src, 0644); err != nil {
+		return nil, err
+	}
+
+	var stdout bytes.Buffer
+	cmd := exec.Command("go", "run", ".")
+	cmd.Dir = dir
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("go run: %v", err)
+	}
+	return stdout.Bytes(), nil
+}
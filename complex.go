@@ -0,0 +1,118 @@
+package luar
+
+import (
+	"math/cmplx"
+	"reflect"
+
+	"github.com/yuin/gopher-lua"
+)
+
+func checkComplex(L *lua.LState, idx int) complex128 {
+	ref, _ := check(L, idx)
+	switch ref.Kind() {
+	case reflect.Complex64, reflect.Complex128:
+		return ref.Complex()
+	}
+	L.ArgError(idx, "expecting complex number")
+	panic("never reaches")
+}
+
+// complexOperands converts both operands of a binary metamethod to the
+// complex type of whichever operand is the userdata.
+func complexOperands(L *lua.LState) (typ reflect.Type, a, b complex128) {
+	typ = basicOperandType(L, isComplexKind)
+
+	x, err := lValueToReflect(L, L.CheckAny(1), typ, nil)
+	if err != nil {
+		L.ArgError(1, err.Error())
+	}
+	y, err := lValueToReflect(L, L.CheckAny(2), typ, nil)
+	if err != nil {
+		L.ArgError(2, err.Error())
+	}
+	return typ, x.Complex(), y.Complex()
+}
+
+func isComplexKind(k reflect.Kind) bool {
+	return k == reflect.Complex64 || k == reflect.Complex128
+}
+
+func pushComplex(L *lua.LState, typ reflect.Type, c complex128) {
+	ret := reflect.New(typ).Elem()
+	ret.SetComplex(c)
+	L.Push(New(L, ret.Interface()))
+}
+
+func complexArithFunc(op string) lua.LGFunction {
+	return func(L *lua.LState) int {
+		typ, a, b := complexOperands(L)
+
+		var c complex128
+		switch op {
+		case "add":
+			c = a + b
+		case "sub":
+			c = a - b
+		case "mul":
+			c = a * b
+		case "div":
+			c = a / b
+		case "pow":
+			c = cmplx.Pow(a, b)
+		}
+		pushComplex(L, typ, c)
+		return 1
+	}
+}
+
+func complexUnm(L *lua.LState) int {
+	ref, _ := check(L, 1)
+	pushComplex(L, ref.Type(), -checkComplex(L, 1))
+	return 1
+}
+
+func complexEq(L *lua.LState) int {
+	a := checkComplex(L, 1)
+	b := checkComplex(L, 2)
+
+	L.Push(lua.LBool(a == b))
+	return 1
+}
+
+func complexReal(L *lua.LState) int {
+	L.Push(lua.LNumber(real(checkComplex(L, 1))))
+	return 1
+}
+
+func complexImag(L *lua.LState) int {
+	L.Push(lua.LNumber(imag(checkComplex(L, 1))))
+	return 1
+}
+
+func complexAbs(L *lua.LState) int {
+	L.Push(lua.LNumber(cmplx.Abs(checkComplex(L, 1))))
+	return 1
+}
+
+func complexPhase(L *lua.LState) int {
+	L.Push(lua.LNumber(cmplx.Phase(checkComplex(L, 1))))
+	return 1
+}
+
+func complexConj(L *lua.LState) int {
+	ref, _ := check(L, 1)
+	pushComplex(L, ref.Type(), cmplx.Conj(checkComplex(L, 1)))
+	return 1
+}
+
+func addComplexMethods(L *lua.LState, tbl *lua.LTable) {
+	for name, fn := range map[string]lua.LGFunction{
+		"real":  complexReal,
+		"imag":  complexImag,
+		"abs":   complexAbs,
+		"phase": complexPhase,
+		"conj":  complexConj,
+	} {
+		tbl.RawSetString(name, newFunction(L, fn))
+	}
+}
@@ -17,6 +17,10 @@ type Config struct {
 	//  - if the tag is "-", no name is returned (i.e. the field is not
 	//    accessible)
 	//  - for any other tag value, that value is returned
+	//
+	// Options following a comma in the tag (e.g. `luar:",extra"`) are not
+	// part of the name. The extra field (see UnknownKeysCollect) is never
+	// given a name, whether FieldNames is nil or not.
 	FieldNames func(s reflect.Type, f reflect.StructField) []string
 
 	// The name generating function that defines under which names Go
@@ -26,8 +30,130 @@ type Config struct {
 	//   - the method name and its name with a lowercase first letter
 	MethodNames func(t reflect.Type, m reflect.Method) []string
 
-	regular map[reflect.Type]*lua.LTable
-	types   *lua.LTable
+	// The function that decides whether values of a named bool, numeric, or
+	// string type (e.g. "type Status int") are converted to userdata that
+	// keeps the type's methods, rather than to a plain Lua value.
+	//
+	// If nil, named types are converted like their underlying type. To opt
+	// in for every named type, use a function that always returns true.
+	NamedTypes func(t reflect.Type) bool
+
+	// If true, converting the same pointer, map, channel, or slice value
+	// (i.e. the same slice header) with New returns the same
+	// *lua.LUserData for as long as that userdata is reachable. This allows
+	// such values to be compared using rawequal and used as table keys.
+	//
+	// Pointers to zero-size values, and slices of zero-size elements or with
+	// no capacity, are not cached: distinct values of that kind may share
+	// an address, so they cannot be told apart.
+	//
+	// When built with a Go version older than 1.24, the userdata are kept
+	// until the state is garbage collected.
+	IdentityCache bool
+
+	// If true, values that implement io.Closer are tracked when they are
+	// converted by New, including values returned from Go functions called
+	// by Lua. Tracked values are closed by ReleaseAll and Close, unless a
+	// script has already closed them through their Close method.
+	//
+	// (*lua.LState).Close does not release the tracked values: gopher-lua
+	// does not notify luar when a state is closed, so luar.Close must be
+	// used in its place.
+	TrackClosers bool
+
+	// If true, a Go function called from Lua whose last return value is a
+	// non-nil error raises that error as a Lua error, rather than returning
+	// it. The raised value is a *lua.LUserData holding the error, so the
+	// error can be inspected with pcall and passed back to Go unchanged.
+	// UnwrapError returns the original error from the error returned by
+	// (*lua.LState).PCall or DoString.
+	RaiseErrors bool
+
+	// If true, Go functions created from Lua functions (e.g. when a Lua
+	// function is passed to a Go function parameter) tolerate the Lua
+	// function returning a different number of values than the Go function
+	// type: missing values are set to their zero value, and extra values
+	// are ignored. Returned nil values are also set to their zero value.
+	//
+	// Additionally, if the Go function type's last result is error, errors
+	// raised by the Lua function and errors converting its return values
+	// are returned as that result, rather than causing a panic. A string
+	// returned in its place is converted to an error.
+	//
+	// The option applies to functions converted while it is set.
+	LenientFuncs bool
+
+	// The policy used to convert Lua numbers and strings to Go numbers and
+	// strings. By default, numbers are converted like a Go conversion from
+	// float64 (e.g. 3.7 is converted to 3 when passed to an int parameter).
+	Conversion ConversionPolicy
+
+	// If true, Lua values converted to an empty interface (e.g. passed to a
+	// Go function parameter of type interface{}, or stored in a
+	// map[string]interface{}) are converted to the types most Go libraries
+	// (e.g. encoding/json) expect:
+	//   - tables whose keys are 1 to n are converted to []interface{}
+	//   - tables whose keys are all strings are converted to
+	//     map[string]interface{}
+	//   - other non-empty tables are converted to
+	//     map[interface{}]interface{}, as by default
+	//   - empty tables are converted according to EmptyTables
+	//   - integral numbers are converted to int64, and other numbers to
+	//     float64
+	//
+	// By default, tables are converted to map[interface{}]interface{}, and
+	// numbers to float64.
+	IdiomaticUntyped bool
+
+	// What empty tables are converted to when IdiomaticUntyped is set.
+	EmptyTables EmptyTablePolicy
+
+	// If true, assigning a table to a field of a struct pointer from Lua
+	// (e.g. "config.server = {port = 8080}") merges the table into the
+	// field's current value, as Merge does, rather than replacing it with a
+	// value converted from the table.
+	//
+	// As with Merge, the field is only set if merging and validation
+	// succeed, but the structs, maps, and slice elements it refers to are
+	// changed in place: a failed assignment may leave them partially
+	// updated.
+	MergeAssign bool
+
+	// How tables are merged into slices and maps by Merge (and by
+	// assignments when MergeAssign is set). By default, slices are replaced
+	// and the keys of maps are merged.
+	MergeSlices SliceMergePolicy
+	MergeMaps   MapMergePolicy
+
+	// How the keys of a table that are not struct field names are handled
+	// when the table is converted to a struct. By default, an error is
+	// returned for unknown string keys, and other keys are ignored.
+	UnknownKeys UnknownKeyPolicy
+
+	// If true, Go panics raised while Lua calls Go code through luar (e.g.
+	// a function converted by New, or a metamethod accessing a nil
+	// embedded struct pointer) are not recovered, and unwind through the
+	// Lua state as they would in older versions of luar.
+	//
+	// By default, such panics are recovered and raised as Lua errors that
+	// include the name of the Go function and a trimmed Go stack trace.
+	PropagatePanics bool
+
+	// The registry from which the fields and methods of Go types are read.
+	// When set, the registry's naming functions are used in place of
+	// FieldNames and MethodNames. A registry can be shared by any number of
+	// states, so that the reflection work is only done once per type.
+	//
+	// The registry must be set before any value is converted.
+	Registry *TypeRegistry
+
+	regular   map[reflect.Type]*lua.LTable
+	types     *lua.LTable
+	identity  *identityCache
+	resources *resources
+	scheduler *Scheduler
+	blocking  *lua.LFunction
+	threads   []callThread
 }
 
 func newConfig() *Config {
@@ -53,7 +179,7 @@ func GetConfig(L *lua.LState) *Config {
 func defaultFieldNames(s reflect.Type, f reflect.StructField) []string {
 	const tagName = "luar"
 
-	tag := f.Tag.Get(tagName)
+	tag := tagNameOnly(f.Tag.Get(tagName))
 	if tag == "-" {
 		return nil
 	}
@@ -72,3 +198,7 @@ func defaultMethodNames(t reflect.Type, m reflect.Method) []string {
 		getUnexportedName(m.Name),
 	}
 }
+
+func (c *Config) preserveNamed(t reflect.Type) bool {
+	return c.NamedTypes != nil && c.NamedTypes(t)
+}
@@ -0,0 +1,120 @@
+package luar
+
+import (
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/yuin/gopher-lua"
+)
+
+// ConversionPolicy defines how Lua numbers and strings are converted to Go
+// numbers and strings (e.g. when passed to a Go function).
+type ConversionPolicy int
+
+const (
+	// ConvertDefault converts numbers like a Go conversion from float64: the
+	// fractional part is discarded, and integers that overflow wrap around
+	// or are truncated. Strings are not converted to numbers, nor numbers to
+	// strings.
+	ConvertDefault ConversionPolicy = iota
+
+	// ConvertStrict converts numbers like ConvertDefault, except that an
+	// error is raised rather than losing a fractional part, the sign of a
+	// negative number converted to an unsigned type, or a value that
+	// overflows the type. Rounding to the precision of float32 is allowed.
+	ConvertStrict
+
+	// ConvertLenient converts numbers like ConvertDefault, and additionally
+	// converts strings to numbers and numbers to strings, as Lua does for
+	// arithmetic and concatenation.
+	ConvertLenient
+)
+
+// numberLoss returns a description of what would be lost by converting n to
+// the numeric type hint, or "" if nothing would be lost.
+func numberLoss(n lua.LNumber, hint reflect.Type) string {
+	f := float64(n)
+	kind := hint.Kind()
+
+	if kind == reflect.Float32 {
+		if math.IsInf(float64(float32(f)), 0) && !math.IsInf(f, 0) {
+			return "value would overflow: " + hint.String() + " has a maximum magnitude of " + strconv.FormatFloat(math.MaxFloat32, 'g', -1, 32)
+		}
+		return ""
+	}
+	if kind == reflect.Float64 {
+		return ""
+	}
+
+	if math.IsNaN(f) {
+		return "NaN has no integer value"
+	}
+
+	var (
+		bits     = hint.Bits()
+		min, max float64
+	)
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		min = -math.Ldexp(1, bits-1)
+		max = math.Ldexp(1, bits-1)
+	default:
+		if f < 0 {
+			return "sign would be lost: " + hint.String() + " is unsigned"
+		}
+		max = math.Ldexp(1, bits)
+	}
+
+	if _, frac := math.Modf(f); frac != 0 && !math.IsInf(f, 0) {
+		return "fractional part " + fractionalPart(f) + " would be lost"
+	}
+	if f < min || f >= max {
+		return "value would overflow: " + hint.String() + " ranges from " + strconv.FormatInt(int64(min), 10) + " to " + integerMax(kind, bits)
+	}
+	return ""
+}
+
+// fractionalPart returns the fractional part of f, formatted without the
+// rounding errors of subtracting its integer part.
+func fractionalPart(f float64) string {
+	s := strconv.FormatFloat(math.Abs(f), 'f', -1, 64)
+	return "0" + s[strings.IndexByte(s, '.'):]
+}
+
+func integerMax(kind reflect.Kind, bits int) string {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(math.MaxInt64>>uint(64-bits), 10)
+	}
+	return strconv.FormatUint(math.MaxUint64>>uint(64-bits), 10)
+}
+
+// parseNumber converts s to a number like Lua's tonumber, with base 10 or a
+// hexadecimal "0x" prefix.
+func parseNumber(s string) (lua.LNumber, bool) {
+	s = strings.TrimSpace(s)
+	if strings.ContainsAny(s, "nN_") {
+		// "inf", "nan", and digit separators are accepted by ParseFloat,
+		// but are not numbers in Lua
+		return 0, false
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return lua.LNumber(f), true
+	}
+
+	negative := strings.HasPrefix(s, "-")
+	hex := strings.TrimPrefix(s, "-")
+	if !strings.HasPrefix(hex, "0x") && !strings.HasPrefix(hex, "0X") {
+		return 0, false
+	}
+	u, err := strconv.ParseUint(hex[2:], 16, 64)
+	if err != nil {
+		return 0, false
+	}
+	if negative {
+		return -lua.LNumber(u), true
+	}
+	return lua.LNumber(u), true
+}
@@ -0,0 +1,119 @@
+package luar
+
+import (
+	"testing"
+
+	"github.com/yuin/gopher-lua"
+)
+
+type conversionTestLevel uint8
+
+type conversionTestValues struct {
+	I   int
+	I8  int8
+	I64 int64
+	U   uint
+	U16 uint16
+	U64 uint64
+	F32 float32
+	F64 float64
+	S   string
+	L   conversionTestLevel
+}
+
+func conversionTestState(policy ConversionPolicy) (*lua.LState, *conversionTestValues) {
+	L := lua.NewState()
+	GetConfig(L).Conversion = policy
+
+	values := &conversionTestValues{}
+	L.SetGlobal("v", New(L, values))
+	L.SetGlobal("int8", New(L, func(i int8) int8 { return i }))
+	L.SetGlobal("double", New(L, func(i int) int { return i * 2 }))
+	L.SetGlobal("concat", New(L, func(a, b string) string { return a + b }))
+	return L, values
+}
+
+func Test_conversion_default(t *testing.T) {
+	L, values := conversionTestState(ConvertDefault)
+	defer L.Close()
+
+	testReturn(t, L, `v.I = 3.7; v.I8 = 300; v.F32 = 1.5; return v.I, v.I8, v.F32`, "3", "44", "1.5")
+	testReturn(t, L, `return int8(300), double(2.9)`, "44", "4")
+	testError(t, L, `v.I = "42"`, "cannot use 42 (type lua.LString) as type int")
+	testError(t, L, `return concat("a", 1)`, "cannot use 1 (type lua.LNumber) as type string")
+	if values.I != 3 {
+		t.Fatalf("unexpected value %d", values.I)
+	}
+}
+
+func Test_conversion_strict(t *testing.T) {
+	L, values := conversionTestState(ConvertStrict)
+	defer L.Close()
+
+	testReturn(t, L, `v.I = 42; v.I8 = -128; v.U = 7; v.F32 = 0.1; v.L = 255; return v.I, v.I8, v.U, v.L`, "42", "-128", "7", "255")
+	testReturn(t, L, `return int8(127), double(21)`, "127", "42")
+	testReturn(t, L, `v.F64 = 0.5; v.F32 = 1/0; return v.F64, v.F32`, "0.5", "+Inf")
+	testReturn(t, L, `v.I64 = -9223372036854775808; v.U64 = 18446744073709549568; return v.I64 == -9223372036854775808, v.U64 == 18446744073709549568`, "true", "true")
+
+	testError(t, L, `v.I = 3.7`, "cannot use 3.7 (type lua.LNumber) as type int: fractional part 0.7 would be lost")
+	testError(t, L, `v.I = -0.25`, "as type int: fractional part 0.25 would be lost")
+	testError(t, L, `v.I8 = 300`, "cannot use 300 (type lua.LNumber) as type int8: value would overflow: int8 ranges from -128 to 127")
+	testError(t, L, `v.U16 = 65536`, "as type uint16: value would overflow: uint16 ranges from 0 to 65535")
+	testError(t, L, `v.U = -1`, "cannot use -1 (type lua.LNumber) as type uint: sign would be lost: uint is unsigned")
+	testError(t, L, `v.L = 256`, "as type luar.conversionTestLevel: value would overflow: luar.conversionTestLevel ranges from 0 to 255")
+	testError(t, L, `v.I = 0/0`, "NaN has no integer value")
+	testError(t, L, `v.I = 1/0`, "value would overflow")
+	testError(t, L, `v.I64 = 9223372036854775808`, "as type int64: value would overflow: int64 ranges from -9223372036854775808 to 9223372036854775807")
+	testError(t, L, `v.U64 = 18446744073709551616`, "as type uint64: value would overflow")
+	testError(t, L, `return double(1e300)`, "value would overflow")
+	testError(t, L, `v.F32 = 1e300`, "as type float32: value would overflow: float32 has a maximum magnitude of 3.4028235e+38")
+	testError(t, L, `return int8(-129)`, "value would overflow: int8 ranges from -128 to 127")
+	testError(t, L, `return double(2.5)`, "fractional part 0.5 would be lost")
+	testError(t, L, `v.I = "42"`, "cannot use 42 (type lua.LString) as type int")
+
+	if values.I != 42 || values.I8 != -128 || values.U != 7 {
+		t.Fatalf("unexpected values %+v", *values)
+	}
+}
+
+func Test_conversion_lenient(t *testing.T) {
+	L, values := conversionTestState(ConvertLenient)
+	defer L.Close()
+
+	testReturn(t, L, `v.I = "42"; v.F64 = " 1.5 "; v.U = "0x10"; v.I8 = "-0x2"; return v.I, v.F64, v.U, v.I8`, "42", "1.5", "16", "-2")
+	testReturn(t, L, `v.S = 12; v.I = 3.7; return v.S, v.I`, "12", "3")
+	testReturn(t, L, `v.S = 1.5; return v.S`, "1.5")
+	testReturn(t, L, `return double("21"), concat("a", 1)`, "42", "a1")
+
+	testError(t, L, `v.I = "abc"`, "cannot use abc (type lua.LString) as type int: not a number")
+	testError(t, L, `v.F64 = "inf"`, "not a number")
+	testError(t, L, `v.I = "1_000"`, "not a number")
+
+	if values.S != "1.5" || values.U != 16 {
+		t.Fatalf("unexpected values %+v", *values)
+	}
+}
+
+func Test_conversion_parseNumber(t *testing.T) {
+	for _, test := range []struct {
+		str      string
+		expected lua.LNumber
+		ok       bool
+	}{
+		{"42", 42, true},
+		{"  -1.5e2\t", -150, true},
+		{"0xff", 255, true},
+		{"-0XA", -10, true},
+		{".5", 0.5, true},
+		{"", 0, false},
+		{"0x", 0, false},
+		{"12abc", 0, false},
+		{"nan", 0, false},
+		{"Infinity", 0, false},
+	} {
+		n, ok := parseNumber(test.str)
+		if n != test.expected || ok != test.ok {
+			t.Fatalf("%q: expecting %v, %v, got %v, %v", test.str, test.expected, test.ok, n, ok)
+		}
+	}
+}
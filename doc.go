@@ -15,16 +15,34 @@
 //
 // lua.LBool values are converted to bool.
 //
-// lua.LNumber values are converted to float64.
+// lua.LNumber values are converted to float64 (or int64, see
+// Config.IdiomaticUntyped). They can also be converted to a complex number
+// with a zero imaginary part.
 //
 // lua.LString values are converted to string.
 //
+// lua.LNumber and lua.LString values can also be converted to *big.Int,
+// *big.Float, and *big.Rat. Strings are parsed using the SetString method of
+// the respective type. Numbers with a fractional part cannot be converted to
+// *big.Int.
+//
 // lua.LChannel values are converted to lua.LChannel.
 //
 // *lua.LTable values can be converted to an array, slice, map, struct, or
-// struct pointer. If the table is being assigned with no type information (i.e.
-// to an interface{}), the converted value will have the type
-// map[interface{}]interface{}.
+// struct pointer (see Config.UnknownKeys for the keys that do not name a
+// struct field). A table {re, im} of two numbers can be converted to a
+// complex number. If the table is being assigned with no type information
+// (i.e. to an interface{}), the converted value will have the type
+// map[interface{}]interface{}, unless Config.IdiomaticUntyped is set. These
+// conversions copy the table; a table converted to a *Table is instead a live
+// view of the table (see Table). Merge overlays a table onto an existing value
+// instead. Structs converted from tables are given defaults and validated by
+// their Defaulter and Validator methods.
+//
+// Struct fields are named according to Config.FieldNames. By default, the
+// name given by a field's "luar" tag ends at its first comma, as options may
+// follow it: a tag such as `luar:"a,b"`, which named the field "a,b" in
+// earlier versions, now names it "a".
 //
 // The Value field of *lua.LUserData values are converted rather than the
 // *lua.LUserData value itself.
@@ -35,6 +53,15 @@
 // being assigned with no type information (i.e. to a interface{}), the function
 // will have the signature func(...interface{}) []interface{}. The arguments
 // and return values will be converted using the standard luar conversion rules.
+// By default, the function panics if the Lua function raises an error or
+// returns an unexpected number of values; see Config.LenientFuncs.
+//
+// Releasing resources
+//
+// When Config.TrackClosers is set, the io.Closer values converted by New are
+// tracked, and closed by ReleaseAll and Close. (*lua.LState).Close does not
+// close them, as gopher-lua does not notify luar when a state is closed:
+// states that track closers must be closed with luar.Close instead.
 //
 // Thread safety
 //
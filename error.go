@@ -0,0 +1,81 @@
+package luar
+
+import (
+	"errors"
+	"reflect"
+
+	"github.com/yuin/gopher-lua"
+)
+
+var refTypeError = reflect.TypeOf((*error)(nil)).Elem()
+
+func errorUnwrap(L *lua.LState) int {
+	err, ok := L.CheckUserData(1).Value.(error)
+	if !ok {
+		L.ArgError(1, "expecting error")
+	}
+	L.Push(New(L, errors.Unwrap(err)))
+	return 1
+}
+
+func errorIs(L *lua.LState) int {
+	err, ok := L.CheckUserData(1).Value.(error)
+	if !ok {
+		L.ArgError(1, "expecting error")
+	}
+	target, ok := L.CheckUserData(2).Value.(error)
+	if !ok {
+		L.ArgError(2, "expecting error")
+	}
+	L.Push(lua.LBool(errors.Is(err, target)))
+	return 1
+}
+
+// addErrorMethods adds the Unwrap and is helpers to the method table of a
+// type that implements error, unless the type defines methods with the same
+// names.
+func addErrorMethods(L *lua.LState, tbl *lua.LTable) {
+	if tbl.RawGetString("Unwrap") == lua.LNil {
+		tbl.RawSetString("Unwrap", newFunction(L, errorUnwrap))
+	}
+	if tbl.RawGetString("is") == lua.LNil {
+		tbl.RawSetString("is", newFunction(L, errorIs))
+	}
+}
+
+// errorUserData converts err to a *lua.LUserData, even if New would convert
+// err's type to a different Lua value.
+func errorUserData(L *lua.LState, err error) *lua.LUserData {
+	if ud, ok := New(L, err).(*lua.LUserData); ok {
+		return ud
+	}
+	ud := L.NewUserData()
+	ud.Value = err
+	if typ := reflect.TypeOf(err); isBasicKind(typ.Kind()) {
+		ud.Metatable = getMetatable(L, typ)
+	}
+	return ud
+}
+
+// raiseError raises err as a Lua error whose value is a *lua.LUserData
+// holding err.
+func raiseError(L *lua.LState, err error) {
+	L.Error(errorUserData(L, err), 1)
+}
+
+// UnwrapError returns the Go error carried by err, if err is a *lua.ApiError
+// whose value is a Go error converted by luar (e.g. an error raised by a Go
+// function with Config.RaiseErrors set, and not caught by the script).
+// Otherwise, err is returned.
+func UnwrapError(err error) error {
+	apiErr, ok := err.(*lua.ApiError)
+	if !ok {
+		return err
+	}
+	if ud, ok := apiErr.Object.(*lua.LUserData); ok {
+		if goErr, ok := ud.Value.(error); ok {
+			return goErr
+		}
+	}
+	return err
+}
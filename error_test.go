@@ -0,0 +1,89 @@
+package luar
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/yuin/gopher-lua"
+)
+
+type TestErrorCode string
+
+func (e TestErrorCode) Error() string {
+	return "code " + string(e)
+}
+
+func Test_error_methods(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	wrapped := fmt.Errorf("reading config: %w", io.EOF)
+
+	L.SetGlobal("err", New(L, wrapped))
+	L.SetGlobal("EOF", New(L, io.EOF))
+	L.SetGlobal("other", New(L, io.ErrUnexpectedEOF))
+
+	testReturn(t, L, `return tostring(err), err:Error()`, "reading config: EOF", "reading config: EOF")
+	testReturn(t, L, `return err:is(EOF), err:is(other)`, "true", "false")
+	testReturn(t, L, `return err:Unwrap() == EOF, EOF:Unwrap()`, "true", "nil")
+}
+
+func Test_error_raise(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	GetConfig(L).RaiseErrors = true
+
+	sentinel := errors.New("sentinel")
+	wrapped := fmt.Errorf("fail: %w", sentinel)
+
+	L.SetGlobal("fail", New(L, func(fail bool) (int, error) {
+		if fail {
+			return 0, wrapped
+		}
+		return 1, nil
+	}))
+	L.SetGlobal("code", New(L, func() error {
+		return TestErrorCode("E1")
+	}))
+	L.SetGlobal("sentinel", New(L, sentinel))
+
+	var got error
+	L.SetGlobal("check", New(L, func(err error) {
+		got = err
+	}))
+
+	testReturn(t, L, `return fail(false)`, "1", "nil")
+	testReturn(t, L, `local ok, err = pcall(fail, true); return ok, tostring(err), err:is(sentinel)`, "false", "fail: sentinel", "true")
+	testReturn(t, L, `local ok, err = pcall(code); return type(err), err:Error()`, "userdata", "code E1")
+
+	testReturn(t, L, `local ok, err = pcall(fail, true); check(err)`)
+	if got != wrapped {
+		t.Fatalf("expecting original error, got %#v", got)
+	}
+
+	err := L.DoString(`local ok, err = pcall(fail, true); error(err)`)
+	if unwrapped := UnwrapError(err); unwrapped != wrapped {
+		t.Fatalf("expecting original error, got %#v", unwrapped)
+	}
+	if !errors.Is(UnwrapError(L.DoString(`fail(true)`)), sentinel) {
+		t.Fatal("expecting error to wrap sentinel")
+	}
+
+	if err := L.DoString(`error("plain")`); UnwrapError(err) != err {
+		t.Fatalf("expecting plain errors to be returned unchanged")
+	}
+}
+
+func Test_error_raise_disabled(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	L.SetGlobal("fail", New(L, func() error {
+		return io.EOF
+	}))
+
+	testReturn(t, L, `return tostring(fail())`, "EOF")
+}
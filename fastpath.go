@@ -0,0 +1,171 @@
+package luar
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/yuin/gopher-lua"
+)
+
+// FastPath calls fn, a Go function of the type the fast path was registered
+// for, with the arguments on L's stack, without using reflection. It returns
+// the number of values it pushed to the stack.
+//
+// If the arguments are not ones the fast path handles (e.g. there are too
+// many, or one has an unexpected Lua type), it must return -1 without
+// modifying the stack. The call is then performed like for any other
+// function, which raises the appropriate error. So that Config.Conversion is
+// respected, a fast path should only handle arguments that are converted
+// without loss (e.g. it should return -1 if 1.5 is passed to an int
+// parameter).
+type FastPath func(L *lua.LState, fn interface{}) int
+
+var fastPaths = struct {
+	sync.RWMutex
+	m map[reflect.Type]FastPath
+}{
+	m: map[reflect.Type]FastPath{
+		reflect.TypeOf(func() {}): func(L *lua.LState, fn interface{}) int {
+			if L.GetTop() != 0 {
+				return -1
+			}
+			fn.(func())()
+			return 0
+		},
+		reflect.TypeOf(func(string) string { return "" }): func(L *lua.LState, fn interface{}) int {
+			a, ok := fastString(L, 1, 1)
+			if !ok {
+				return -1
+			}
+			L.Push(lua.LString(fn.(func(string) string)(a)))
+			return 1
+		},
+		reflect.TypeOf(func(string) bool { return false }): func(L *lua.LState, fn interface{}) int {
+			a, ok := fastString(L, 1, 1)
+			if !ok {
+				return -1
+			}
+			L.Push(lua.LBool(fn.(func(string) bool)(a)))
+			return 1
+		},
+		reflect.TypeOf(func(string) int { return 0 }): func(L *lua.LState, fn interface{}) int {
+			a, ok := fastString(L, 1, 1)
+			if !ok {
+				return -1
+			}
+			L.Push(lua.LNumber(fn.(func(string) int)(a)))
+			return 1
+		},
+		reflect.TypeOf(func(float64) float64 { return 0 }): func(L *lua.LState, fn interface{}) int {
+			a, ok := fastNumber(L, 1, 1)
+			if !ok {
+				return -1
+			}
+			L.Push(lua.LNumber(fn.(func(float64) float64)(float64(a))))
+			return 1
+		},
+		reflect.TypeOf(func(float64, float64) float64 { return 0 }): func(L *lua.LState, fn interface{}) int {
+			a, ok1 := fastNumber(L, 1, 2)
+			b, ok2 := fastNumber(L, 2, 2)
+			if !ok1 || !ok2 {
+				return -1
+			}
+			L.Push(lua.LNumber(fn.(func(float64, float64) float64)(float64(a), float64(b))))
+			return 1
+		},
+		reflect.TypeOf(func(int) int { return 0 }): func(L *lua.LState, fn interface{}) int {
+			a, ok := fastInt(L, 1, 1)
+			if !ok {
+				return -1
+			}
+			L.Push(lua.LNumber(fn.(func(int) int)(a)))
+			return 1
+		},
+		reflect.TypeOf(func(int) bool { return false }): func(L *lua.LState, fn interface{}) int {
+			a, ok := fastInt(L, 1, 1)
+			if !ok {
+				return -1
+			}
+			L.Push(lua.LBool(fn.(func(int) bool)(a)))
+			return 1
+		},
+		reflect.TypeOf(func(int, int) int { return 0 }): func(L *lua.LState, fn interface{}) int {
+			a, ok1 := fastInt(L, 1, 2)
+			b, ok2 := fastInt(L, 2, 2)
+			if !ok1 || !ok2 {
+				return -1
+			}
+			L.Push(lua.LNumber(fn.(func(int, int) int)(a, b)))
+			return 1
+		},
+	},
+}
+
+// RegisterFastPath registers path as the fast path for Go functions of the
+// same type as sample, which must be a function (it may be nil). The fast
+// path is used by functions converted after it is registered, replacing any
+// previously registered fast path, including luar's own fast paths for
+// common signatures (e.g. func(string) string). If path is nil, the fast
+// path for the type is removed.
+//
+// Fast paths are not used for functions with the signature
+// func(*LState) int.
+func RegisterFastPath(sample interface{}, path FastPath) {
+	t := reflect.TypeOf(sample)
+	if t == nil || t.Kind() != reflect.Func {
+		panic("luar: RegisterFastPath expects a function")
+	}
+
+	fastPaths.Lock()
+	defer fastPaths.Unlock()
+	if path == nil {
+		delete(fastPaths.m, t)
+		return
+	}
+	fastPaths.m[t] = path
+}
+
+func getFastPath(t reflect.Type) FastPath {
+	fastPaths.RLock()
+	defer fastPaths.RUnlock()
+	return fastPaths.m[t]
+}
+
+// fastString returns the string argument at idx, if there are n arguments.
+func fastString(L *lua.LState, idx, n int) (string, bool) {
+	if L.GetTop() != n {
+		return "", false
+	}
+	str, ok := L.Get(idx).(lua.LString)
+	return string(str), ok
+}
+
+// fastNumber returns the number argument at idx, if there are n arguments.
+func fastNumber(L *lua.LState, idx, n int) (lua.LNumber, bool) {
+	if L.GetTop() != n {
+		return 0, false
+	}
+	num, ok := L.Get(idx).(lua.LNumber)
+	return num, ok
+}
+
+// fastInt returns the integer argument at idx, if there are n arguments and
+// the argument is an int.
+func fastInt(L *lua.LState, idx, n int) (int, bool) {
+	num, ok := fastNumber(L, idx, n)
+	if !ok || numberLoss(num, refTypeInt) != "" {
+		return 0, false
+	}
+	return int(num), true
+}
+
+// funcFast returns a function that calls fn using path, falling back to
+// funcRegular.
+func funcFast(fn interface{}, path FastPath) lua.LGFunction {
+	return func(L *lua.LState) int {
+		if n := path(L, fn); n >= 0 {
+			return n
+		}
+		return funcRegular(L)
+	}
+}
@@ -0,0 +1,55 @@
+package luar
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/yuin/gopher-lua"
+)
+
+func Test_fastpath_builtin(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	L.SetGlobal("upper", New(L, strings.ToUpper))
+	L.SetGlobal("double", New(L, func(i int) int { return i * 2 }))
+	L.SetGlobal("even", New(L, func(i int) bool { return i%2 == 0 }))
+	L.SetGlobal("hypot", New(L, func(a, b float64) float64 { return a*a + b*b }))
+
+	testReturn(t, L, `return upper("abc")`, "ABC")
+	testReturn(t, L, `return double(21), double(2.9)`, "42", "4")
+	testReturn(t, L, `return even(2), even(3)`, "true", "false")
+	testReturn(t, L, `return hypot(3, 4)`, "25")
+
+	// fallback to the regular conversion
+	testError(t, L, `return upper(123)`, "cannot use 123 (type lua.LNumber) as type string")
+	testError(t, L, `return upper()`, "invalid number of function arguments (1 expected, got 0)")
+	testError(t, L, `return double("x")`, "bad argument #1")
+}
+
+type fastpathGreeter func(name string, times int) string
+
+func Test_fastpath_register(t *testing.T) {
+	calls := 0
+	RegisterFastPath((func(string, int) string)(nil), func(L *lua.LState, fn interface{}) int {
+		if L.GetTop() != 2 {
+			return -1
+		}
+		calls++
+		L.Push(lua.LString(fn.(func(string, int) string)(L.CheckString(1), L.CheckInt(2))))
+		return 1
+	})
+	defer RegisterFastPath((func(string, int) string)(nil), nil)
+
+	L := lua.NewState()
+	defer L.Close()
+
+	L.SetGlobal("repeat_", New(L, strings.Repeat))
+	L.SetGlobal("named", New(L, fastpathGreeter(strings.Repeat)))
+
+	testReturn(t, L, `return repeat_("ab", 2)`, "abab")
+	testReturn(t, L, `return named("ab", 3)`, "ababab")
+	if calls != 1 {
+		t.Fatalf("expecting fast path to be called once, got %d", calls)
+	}
+}
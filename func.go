@@ -1,7 +1,9 @@
 package luar
 
 import (
+	"errors"
 	"reflect"
+	"sync"
 
 	"github.com/yuin/gopher-lua"
 )
@@ -72,9 +74,21 @@ func funcBypass(L *lua.LState) int {
 	return ret
 }
 
-func funcRegular(L *lua.LState) int {
-	ref, refType := getFunc(L)
+// argsPool holds the slices funcRegular converts arguments into, so that
+// calls do not allocate them.
+var argsPool = sync.Pool{
+	New: func() interface{} {
+		args := make([]reflect.Value, 0, 8)
+		return &args
+	},
+}
 
+// funcArgs checks and converts the arguments on L's stack for a call to a
+// function of type refType. The arguments are stored in buf if it is large
+// enough. If the receiver of a pointer receiver method was converted from a
+// non-pointer value, restore must be called after the call to copy the
+// receiver back to its userdata.
+func funcArgs(L *lua.LState, refType reflect.Type, buf []reflect.Value) (args []reflect.Value, restore func()) {
 	top := L.GetTop()
 	expected := refType.NumIn()
 	variadic := refType.IsVariadic()
@@ -89,7 +103,11 @@ func funcRegular(L *lua.LState) int {
 	var receiver reflect.Value
 	var ud lua.LValue
 
-	args := make([]reflect.Value, top)
+	if top <= cap(buf) {
+		args = buf[:top]
+	} else {
+		args = make([]reflect.Value, top)
+	}
 	for i := 0; i < L.GetTop(); i++ {
 		var hint reflect.Type
 		if variadic && i >= expected-1 {
@@ -116,10 +134,34 @@ func funcRegular(L *lua.LState) int {
 		}
 		args[i] = arg
 	}
-	ret := ref.Call(args)
 
 	if convertedPtr {
-		ud.(*lua.LUserData).Value = receiver.Elem().Interface()
+		restore = func() {
+			ud.(*lua.LUserData).Value = receiver.Elem().Interface()
+		}
+	}
+	return
+}
+
+func funcRegular(L *lua.LState) int {
+	ref, refType := getFunc(L)
+
+	buf := argsPool.Get().(*[]reflect.Value)
+	args, restore := funcArgs(L, refType, *buf)
+	ret := ref.Call(args)
+	if cap(args) == cap(*buf) {
+		for i := range args {
+			args[i] = reflect.Value{}
+		}
+		argsPool.Put(buf)
+	}
+
+	if restore != nil {
+		restore()
+	}
+
+	if n := len(ret); n > 0 && refType.Out(n-1) == refTypeError && !ret[n-1].IsNil() && GetConfig(L).RaiseErrors {
+		raiseError(L, ret[n-1].Interface().(error))
 	}
 
 	for _, val := range ret {
@@ -132,8 +174,59 @@ func funcWrapper(L *lua.LState, fn reflect.Value, isPtrReceiverMethod bool) *lua
 	up := L.NewUserData()
 	up.Value = fn
 
+	name := func() string {
+		return funcName(fn)
+	}
 	if funcIsBypass(fn.Type()) {
-		return L.NewClosure(funcBypass, up, lua.LBool(isPtrReceiverMethod))
+		return L.NewClosure(protect(funcBypass, name), up, lua.LBool(isPtrReceiverMethod))
+	}
+	if path := getFastPath(fn.Type()); path != nil {
+		return L.NewClosure(protect(funcFast(fn.Interface(), path), name), up, lua.LBool(isPtrReceiverMethod))
+	}
+	return L.NewClosure(protect(funcRegular, name), up, lua.LBool(isPtrReceiverMethod))
+}
+
+// funcErrorResults returns the zero values of hint's results, with err as the
+// last result.
+func funcErrorResults(hint reflect.Type, err error) []reflect.Value {
+	ret := make([]reflect.Value, hint.NumOut())
+	for i := range ret {
+		ret[i] = reflect.Zero(hint.Out(i))
+	}
+	ret[len(ret)-1] = reflect.ValueOf(&err).Elem()
+	return ret
+}
+
+// lenientFuncResults converts the top values of L's stack, returned from a
+// Lua function, to hint's results. Missing and nil values are set to their
+// zero value, and extra values are ignored. If errorOut is true, conversion errors
+// are returned as the last result, and a string in its place is converted to
+// an error.
+func lenientFuncResults(L *lua.LState, hint reflect.Type, top int, errorOut bool) []reflect.Value {
+	ret := make([]reflect.Value, hint.NumOut())
+	for i := range ret {
+		outHint := hint.Out(i)
+		item := lua.LValue(lua.LNil)
+		if i < top {
+			item = L.Get(i + 1)
+		}
+		if item == lua.LNil {
+			ret[i] = reflect.Zero(outHint)
+			continue
+		}
+		if str, ok := item.(lua.LString); ok && errorOut && i == len(ret)-1 {
+			err := errors.New(string(str))
+			ret[i] = reflect.ValueOf(&err).Elem()
+			continue
+		}
+		val, err := lValueToReflect(L, item, outHint, nil)
+		if err != nil {
+			if errorOut {
+				return funcErrorResults(hint, err)
+			}
+			panic(err)
+		}
+		ret[i] = val
 	}
-	return L.NewClosure(funcRegular, up, lua.LBool(isPtrReceiverMethod))
+	return ret
 }
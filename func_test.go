@@ -1,7 +1,9 @@
 package luar
 
 import (
+	"io"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/yuin/gopher-lua"
@@ -239,3 +241,88 @@ func Test_func_conversionstack(t *testing.T) {
 		t.Fatalf("expected return %#v, got %#v", expected, values)
 	}
 }
+
+func Test_func_lenient(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	GetConfig(L).LenientFuncs = true
+
+	var fn func(int) (string, int, error)
+	L.SetGlobal("set", New(L, func(f func(int) (string, int, error)) {
+		fn = f
+	}))
+
+	testReturn(t, L, `set(function(n) return "a" end)`)
+	if s, n, err := fn(1); s != "a" || n != 0 || err != nil {
+		t.Fatalf("unexpected results %q %d %v", s, n, err)
+	}
+
+	testReturn(t, L, `set(function(n) return "a", n, nil, "extra" end)`)
+	if s, n, err := fn(3); s != "a" || n != 3 || err != nil {
+		t.Fatalf("unexpected results %q %d %v", s, n, err)
+	}
+
+	testReturn(t, L, `set(function(n) error("boom") end)`)
+	if _, _, err := fn(1); err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Fatalf("expecting Lua error, got %v", err)
+	}
+
+	testReturn(t, L, `set(function(n) return nil, 0, "failed" end)`)
+	if _, _, err := fn(1); err == nil || err.Error() != "failed" {
+		t.Fatalf("expecting error result, got %v", err)
+	}
+
+	testReturn(t, L, `set(function(n) return {} end)`)
+	if _, _, err := fn(1); err == nil || !strings.Contains(err.Error(), "cannot use") {
+		t.Fatalf("expecting conversion error, got %v", err)
+	}
+}
+
+func Test_func_lenient_goerror(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	config := GetConfig(L)
+	config.LenientFuncs = true
+	config.RaiseErrors = true
+
+	L.SetGlobal("fail", New(L, func() error {
+		return io.EOF
+	}))
+
+	var fn func() error
+	L.SetGlobal("set", New(L, func(f func() error) {
+		fn = f
+	}))
+
+	testReturn(t, L, `set(function() fail() end)`)
+	if err := fn(); err != io.EOF {
+		t.Fatalf("expecting io.EOF, got %v", err)
+	}
+}
+
+func Test_func_lenient_noerror(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	GetConfig(L).LenientFuncs = true
+
+	var fn func() (int, int)
+	L.SetGlobal("set", New(L, func(f func() (int, int)) {
+		fn = f
+	}))
+
+	testReturn(t, L, `set(function() return 1 end)`)
+	if a, b := fn(); a != 1 || b != 0 {
+		t.Fatalf("unexpected results %d %d", a, b)
+	}
+
+	testReturn(t, L, `set(function() return {} end)`)
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expecting panic")
+		}
+	}()
+	fn()
+}
@@ -0,0 +1,290 @@
+package luar
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+
+	"github.com/yuin/gopher-lua"
+)
+
+// Future is the result of a Go operation that completes asynchronously.
+// Functions converted by New can return a *Future to let scripts start
+// several operations and wait on them together.
+//
+// In Lua, a future has the following methods:
+//  await()  waits for the operation to complete, and returns its value and
+//           error, as if returned by a Go function with the signature
+//           func() (interface{}, error). When called by a coroutine run by a
+//           Scheduler, the coroutine yields until the operation completes
+//           (if Config.RaiseErrors is set, an error then terminates the
+//           coroutine, as it can no longer be raised in it).
+//  ready()  returns true if the operation has completed.
+//  cancel() cancels the operation.
+//
+// The Go methods Result and Done are not available in Lua: they would block
+// the whole state, even in a coroutine run by a Scheduler. await is used
+// instead.
+//
+// Scripts combine futures with All and Any, which are not available in Lua
+// unless the host exposes them, e.g. by preloading FutureLoader.
+type Future struct {
+	done   chan struct{}
+	once   sync.Once
+	cancel context.CancelFunc
+
+	value interface{}
+	err   error
+}
+
+// ErrNoFutures is the error of the future returned by Any when it is called
+// without futures.
+var ErrNoFutures = errors.New("luar: Any called without futures")
+
+var (
+	refTypeFuture  = reflect.TypeOf((*Future)(nil))
+	refTypeContext = reflect.TypeOf((*context.Context)(nil)).Elem()
+)
+
+// NewFuture returns a future whose result is returned by fn, which is called
+// in a new goroutine. ctx is canceled when the future is canceled.
+func NewFuture(fn func(ctx context.Context) (interface{}, error)) *Future {
+	ctx, cancel := context.WithCancel(context.Background())
+	f := &Future{
+		done:   make(chan struct{}),
+		cancel: cancel,
+	}
+	go func() {
+		value, err := fn(ctx)
+		f.resolve(value, err)
+	}()
+	return f
+}
+
+// FutureFunc returns a future whose result is returned by fn, which is called
+// in a new goroutine. fn must be a function that accepts no arguments, or a
+// context.Context that is canceled when the future is canceled, and returns
+// at most one value, optionally followed by an error.
+func FutureFunc(fn interface{}) *Future {
+	ref := reflect.ValueOf(fn)
+	refType := ref.Type()
+	if ref.Kind() != reflect.Func {
+		panic("luar: FutureFunc expects a function, got " + ref.Kind().String())
+	}
+	withContext := refType.NumIn() == 1 && refType.In(0) == refTypeContext
+	if refType.NumIn() > 1 || (refType.NumIn() == 1 && !withContext) || refType.IsVariadic() {
+		panic("luar: FutureFunc expects a function with no arguments or a context.Context argument, got " + refType.String())
+	}
+	hasError := refType.NumOut() > 0 && refType.Out(refType.NumOut()-1) == refTypeError
+	if n := refType.NumOut(); n > 2 || (n == 2 && !hasError) {
+		panic("luar: FutureFunc expects a function that returns a value and an optional error, got " + refType.String())
+	}
+
+	return NewFuture(func(ctx context.Context) (interface{}, error) {
+		var args []reflect.Value
+		if withContext {
+			args = []reflect.Value{reflect.ValueOf(ctx)}
+		}
+		ret := ref.Call(args)
+
+		var err error
+		if hasError {
+			err, _ = ret[len(ret)-1].Interface().(error)
+			ret = ret[:len(ret)-1]
+		}
+		if len(ret) == 0 {
+			return nil, err
+		}
+		return ret[0].Interface(), err
+	})
+}
+
+// FutureChan returns a future whose value is the first value received from
+// ch, which must be a channel that can be received from. If ch is closed
+// without receiving a value, the future's value is nil.
+func FutureChan(ch interface{}) *Future {
+	ref := reflect.ValueOf(ch)
+	if ref.Kind() != reflect.Chan || ref.Type().ChanDir()&reflect.RecvDir == 0 {
+		panic("luar: FutureChan expects a receivable channel, got " + ref.Type().String())
+	}
+
+	return NewFuture(func(ctx context.Context) (interface{}, error) {
+		chosen, value, ok := reflect.Select([]reflect.SelectCase{
+			{Dir: reflect.SelectRecv, Chan: ref},
+			{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())},
+		})
+		if chosen == 1 {
+			return nil, ctx.Err()
+		}
+		if !ok {
+			return nil, nil
+		}
+		return value.Interface(), nil
+	})
+}
+
+func (f *Future) resolve(value interface{}, err error) {
+	f.once.Do(func() {
+		f.value = value
+		f.err = err
+		close(f.done)
+	})
+}
+
+// Done returns a channel that is closed once the future has completed.
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// Ready returns true if the future has completed.
+func (f *Future) Ready() bool {
+	select {
+	case <-f.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Result waits for the future to complete, and returns its value and error.
+func (f *Future) Result() (interface{}, error) {
+	<-f.done
+	return f.value, f.err
+}
+
+// Cancel cancels the future. If the future has not completed, it completes
+// immediately with the error context.Canceled.
+func (f *Future) Cancel() {
+	f.cancel()
+	f.resolve(nil, context.Canceled)
+}
+
+// All returns a future that completes once all of futures have completed.
+// Its value is a []interface{} holding the futures' values, in order. If a
+// future fails, the returned future fails with the same error, and the other
+// futures are canceled. Canceling the returned future cancels futures.
+func All(futures ...*Future) *Future {
+	return NewFuture(func(ctx context.Context) (interface{}, error) {
+		values := make([]interface{}, len(futures))
+		for i, f := range futures {
+			select {
+			case <-f.done:
+			case <-ctx.Done():
+				cancelAll(futures)
+				return nil, ctx.Err()
+			}
+			if f.err != nil {
+				cancelAll(futures)
+				return nil, f.err
+			}
+			values[i] = f.value
+		}
+		return values, nil
+	})
+}
+
+// Any returns a future that completes once any of futures has completed, with
+// the same value and error. The other futures are then canceled. Canceling
+// the returned future cancels futures. If futures is empty, the returned
+// future has already failed with ErrNoFutures.
+func Any(futures ...*Future) *Future {
+	if len(futures) == 0 {
+		f := &Future{
+			done:   make(chan struct{}),
+			cancel: func() {},
+		}
+		f.resolve(nil, ErrNoFutures)
+		return f
+	}
+	return NewFuture(func(ctx context.Context) (interface{}, error) {
+		cases := make([]reflect.SelectCase, 0, len(futures)+1)
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())})
+		for _, f := range futures {
+			cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(f.done)})
+		}
+		chosen, _, _ := reflect.Select(cases)
+		cancelAll(futures)
+		if chosen == 0 {
+			return nil, ctx.Err()
+		}
+		f := futures[chosen-1]
+		return f.value, f.err
+	})
+}
+
+// FutureLoader is a lua.LGFunction that returns a module holding the
+// functions all and any, which are All and Any converted by New. It is
+// typically preloaded:
+//  L.PreloadModule("future", luar.FutureLoader)
+// so that scripts can combine futures:
+//  local future = require("future")
+//  local values, err = future.all(a, b):await()
+func FutureLoader(L *lua.LState) int {
+	mod := L.CreateTable(0, 2)
+	mod.RawSetString("all", New(L, All))
+	mod.RawSetString("any", New(L, Any))
+	L.Push(mod)
+	return 1
+}
+
+func cancelAll(futures []*Future) {
+	for _, f := range futures {
+		f.Cancel()
+	}
+}
+
+func checkFuture(L *lua.LState, idx int) *Future {
+	f, ok := L.CheckUserData(idx).Value.(*Future)
+	if !ok || f == nil {
+		L.ArgError(idx, "expecting future")
+	}
+	return f
+}
+
+func futureAwait(L *lua.LState) int {
+	f := checkFuture(L, 1)
+
+	if s := GetConfig(L).scheduler; s != nil && s.canWait(L) && !f.Ready() {
+		call := func() []reflect.Value {
+			<-f.done
+			return nil
+		}
+		return s.wait(L, "future", call, func(L *lua.LState, _ []reflect.Value, panicMsg string) ([]lua.LValue, error) {
+			if panicMsg != "" {
+				return nil, errors.New(panicMsg)
+			}
+			if f.err != nil && GetConfig(L).RaiseErrors {
+				// the coroutine cannot raise the error once it has yielded
+				return nil, f.err
+			}
+			return []lua.LValue{New(L, f.value), New(L, f.err)}, nil
+		})
+	}
+
+	value, err := f.Result()
+	if err != nil && GetConfig(L).RaiseErrors {
+		raiseError(L, err)
+	}
+	L.Push(New(L, value))
+	L.Push(New(L, err))
+	return 2
+}
+
+// futureMethodHidden returns true if the Go method name of *Future is not
+// added to its method table, as it blocks the state (see Future).
+func futureMethodHidden(name string) bool {
+	return name == "Result" || name == "Done"
+}
+
+// addFutureMethods adds the await, ready, and cancel methods to the method
+// table of *Future.
+func addFutureMethods(L *lua.LState, tbl *lua.LTable) {
+	tbl.RawSetString("await", newFunction(L, futureAwait))
+	if tbl.RawGetString("ready") == lua.LNil {
+		tbl.RawSetString("ready", funcWrapper(L, reflect.ValueOf((*Future).Ready), true))
+	}
+	if tbl.RawGetString("cancel") == lua.LNil {
+		tbl.RawSetString("cancel", funcWrapper(L, reflect.ValueOf((*Future).Cancel), true))
+	}
+}
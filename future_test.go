@@ -0,0 +1,137 @@
+package luar
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/yuin/gopher-lua"
+)
+
+func Test_future_await(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	L.SetGlobal("double", New(L, func(i int) *Future {
+		return FutureFunc(func() (int, error) {
+			return i * 2, nil
+		})
+	}))
+	L.SetGlobal("fail", New(L, func() *Future {
+		return FutureFunc(func() error {
+			return errors.New("failed")
+		})
+	}))
+
+	testReturn(t, L, `local v, err = double(21):await(); return v, err`, "42", "nil")
+	testReturn(t, L, `local f = double(1); f:await(); return f:ready()`, "true")
+	testReturn(t, L, `local v, err = fail():await(); return v, tostring(err)`, "nil", "failed")
+
+	GetConfig(L).RaiseErrors = true
+	if err := UnwrapError(L.DoString(`fail():await()`)); err == nil || err.Error() != "failed" {
+		t.Fatalf("expecting failed error, got %v", err)
+	}
+}
+
+func Test_future_chan(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	ch := make(chan string, 1)
+	L.SetGlobal("f", New(L, FutureChan(ch)))
+
+	testReturn(t, L, `return f:ready()`, "false")
+	ch <- "hello"
+	testReturn(t, L, `return f:await()`, "hello", "nil")
+}
+
+func Test_future_cancel(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	canceled := make(chan struct{})
+	L.SetGlobal("f", New(L, FutureFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		close(canceled)
+		return ctx.Err()
+	})))
+
+	testReturn(t, L, `f:cancel(); local _, err = f:await(); return tostring(err)`, "context canceled")
+	<-canceled
+}
+
+func Test_future_all_any(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	block := make(chan int)
+	L.SetGlobal("value", New(L, func(i int) *Future {
+		return FutureFunc(func() int { return i })
+	}))
+	L.SetGlobal("never", New(L, func() *Future {
+		return FutureChan(block)
+	}))
+	L.SetGlobal("fail", New(L, func() *Future {
+		return FutureFunc(func() error { return errors.New("failed") })
+	}))
+	L.PreloadModule("future", FutureLoader)
+	testReturn(t, L, `local future = require("future"); all, any = future.all, future.any`)
+
+	testReturn(t, L, `local v = all(value(1), value(2), value(3)):await(); return v[1], v[2], v[3]`, "1", "2", "3")
+	testReturn(t, L, `local v, err = all(value(1), fail(), never()):await(); return v, tostring(err)`, "nil", "failed")
+	testReturn(t, L, `return any(never(), value(4)):await()`, "4", "nil")
+	testReturn(t, L, `local f = any(); return f:ready(), tostring(select(2, f:await()))`, "true", ErrNoFutures.Error())
+}
+
+func Test_future_hidden_methods(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	L.SetGlobal("f", New(L, FutureFunc(func() int { return 1 })))
+	testReturn(t, L, `return f.result, f.Result, f.done, f.Done`, "nil", "nil", "nil", "nil")
+	testReturn(t, L, `return f:await()`, "1", "nil")
+}
+
+func Test_future_scheduler(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	release := make(chan string)
+	var events []string
+	L.SetGlobal("wait", New(L, func() *Future {
+		return FutureChan(release)
+	}))
+	L.SetGlobal("release", New(L, func(s string) {
+		go func() { release <- s }()
+	}))
+	L.SetGlobal("record", New(L, func(s string) {
+		events = append(events, s)
+	}))
+
+	if err := L.DoString(`
+		function waiter()
+			local f = wait()
+			record("waiting")
+			record((f:await()))
+		end
+		function releaser()
+			record("releasing")
+			release("released")
+		end
+	`); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewScheduler(L)
+	s.Go(L.GetGlobal("waiter").(*lua.LFunction))
+	s.Go(L.GetGlobal("releaser").(*lua.LFunction))
+	if err := s.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"waiting", "releasing", "released"}
+	if !reflect.DeepEqual(events, expected) {
+		t.Fatalf("expecting %#v, got %#v", expected, events)
+	}
+}
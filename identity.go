@@ -0,0 +1,64 @@
+package luar
+
+import (
+	"reflect"
+
+	"github.com/yuin/gopher-lua"
+)
+
+// identityKey identifies a pointer, map, channel, or slice value. Slices are
+// identified by their entire header.
+type identityKey struct {
+	typ      reflect.Type
+	ptr      uintptr
+	len, cap int
+}
+
+func newIdentityKey(val reflect.Value) identityKey {
+	key := identityKey{
+		typ: val.Type(),
+		ptr: val.Pointer(),
+	}
+	if val.Kind() == reflect.Slice {
+		key.len = val.Len()
+		key.cap = val.Cap()
+	}
+	return key
+}
+
+// identifiable returns true if val, a non-nil pointer, map, channel, or slice,
+// can be identified by its address. Distinct zero-size objects may share an
+// address, so pointers to them and slices of them (or without a backing
+// array) cannot.
+func identifiable(val reflect.Value) bool {
+	switch val.Kind() {
+	case reflect.Ptr:
+		return val.Type().Elem().Size() != 0
+	case reflect.Slice:
+		return val.Type().Elem().Size() != 0 && val.Cap() != 0
+	}
+	return true
+}
+
+// identityUserData returns the userdata previously created for val, or a
+// new userdata if there is none.
+func identityUserData(L *lua.LState, c *Config, val reflect.Value) *lua.LUserData {
+	if c.identity == nil {
+		c.identity = newIdentityCache()
+	}
+
+	key := newIdentityKey(val)
+	// The value of a cached userdata may have been replaced (e.g. by a
+	// pointer receiver method called on a slice), so it is checked again.
+	if ud := c.identity.get(key); ud != nil {
+		if ref := reflect.ValueOf(ud.Value); ref.IsValid() && ref.Type() == key.typ && newIdentityKey(ref) == key {
+			return ud
+		}
+	}
+
+	ud := L.NewUserData()
+	ud.Value = val.Interface()
+	ud.Metatable = getMetatable(L, val.Type())
+	c.identity.set(key, ud)
+	return ud
+}
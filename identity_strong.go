@@ -0,0 +1,28 @@
+//go:build !go1.24
+// +build !go1.24
+
+package luar
+
+import "github.com/yuin/gopher-lua"
+
+type identityCache struct {
+	m map[identityKey]*lua.LUserData
+}
+
+func newIdentityCache() *identityCache {
+	return &identityCache{
+		m: make(map[identityKey]*lua.LUserData),
+	}
+}
+
+func (c *identityCache) get(key identityKey) *lua.LUserData {
+	return c.m[key]
+}
+
+func (c *identityCache) set(key identityKey, ud *lua.LUserData) {
+	c.m[key] = ud
+}
+
+func (c *identityCache) len() int {
+	return len(c.m)
+}
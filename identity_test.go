@@ -0,0 +1,81 @@
+package luar
+
+import (
+	"testing"
+
+	"github.com/yuin/gopher-lua"
+)
+
+func Test_identity_disabled(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	p := &StructTestPerson{Name: "Tim"}
+	L.SetGlobal("a", New(L, p))
+	L.SetGlobal("b", New(L, p))
+
+	testReturn(t, L, `return rawequal(a, b), a == b`, "false", "true")
+}
+
+func Test_identity(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	GetConfig(L).IdentityCache = true
+
+	p := &StructTestPerson{Name: "Tim"}
+	m := map[string]int{}
+	ch := make(chan int)
+	s := make([]int, 2, 10)
+
+	L.SetGlobal("get", New(L, func() (*StructTestPerson, map[string]int, chan int, []int) {
+		return p, m, ch, s
+	}))
+	L.SetGlobal("other", New(L, &StructTestPerson{Name: "Tim"}))
+	L.SetGlobal("grown", New(L, s[:3]))
+
+	testReturn(t, L, `p1, m1, ch1, s1 = get(); p2, m2, ch2, s2 = get()`)
+	testReturn(t, L, `return rawequal(p1, p2), rawequal(m1, m2), rawequal(ch1, ch2), rawequal(s1, s2)`, "true", "true", "true", "true")
+	testReturn(t, L, `return rawequal(p1, other), rawequal(s1, grown)`, "false", "false")
+	testReturn(t, L, `local state = {}; state[p1] = "x"; return state[p2]`, "x")
+}
+
+type TestIdentitySlice []int
+
+func (s *TestIdentitySlice) Push(v int) {
+	*s = append(*s, v)
+}
+
+func Test_identity_replaced_value(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	GetConfig(L).IdentityCache = true
+
+	s := make(TestIdentitySlice, 0, 1)
+	L.SetGlobal("s", New(L, s))
+	L.SetGlobal("s2", New(L, s))
+	L.SetGlobal("push", MT(L, &s).method("Push"))
+
+	testReturn(t, L, `return rawequal(s, s2)`, "true")
+	testReturn(t, L, `push(s, 1); return #s`, "1")
+
+	L.SetGlobal("s3", New(L, s))
+	testReturn(t, L, `return rawequal(s, s3), #s3`, "false", "0")
+}
+
+func Test_identity_zero_size(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	GetConfig(L).IdentityCache = true
+
+	a, b := new(struct{}), new(struct{})
+	L.SetGlobal("a", New(L, a))
+	L.SetGlobal("b", New(L, b))
+	L.SetGlobal("a2", New(L, a))
+	L.SetGlobal("e", New(L, make([]int, 0)))
+	L.SetGlobal("e2", New(L, make([]int, 0)))
+
+	testReturn(t, L, `return rawequal(a, b), rawequal(a, a2), rawequal(e, e2)`, "false", "false", "false")
+}
@@ -0,0 +1,50 @@
+//go:build go1.24
+// +build go1.24
+
+package luar
+
+import (
+	"runtime"
+	"sync"
+	"weak"
+
+	"github.com/yuin/gopher-lua"
+)
+
+type identityCache struct {
+	mu sync.Mutex
+	m  map[identityKey]weak.Pointer[lua.LUserData]
+}
+
+func newIdentityCache() *identityCache {
+	return &identityCache{
+		m: make(map[identityKey]weak.Pointer[lua.LUserData]),
+	}
+}
+
+func (c *identityCache) get(key identityKey) *lua.LUserData {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.m[key].Value()
+}
+
+func (c *identityCache) set(key identityKey, ud *lua.LUserData) {
+	c.mu.Lock()
+	c.m[key] = weak.Make(ud)
+	c.mu.Unlock()
+	runtime.AddCleanup(ud, c.remove, key)
+}
+
+func (c *identityCache) remove(key identityKey) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.m[key].Value() == nil {
+		delete(c.m, key)
+	}
+}
+
+func (c *identityCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.m)
+}
@@ -0,0 +1,34 @@
+//go:build go1.24
+// +build go1.24
+
+package luar
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/yuin/gopher-lua"
+)
+
+func Test_identity_weak(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	config := GetConfig(L)
+	config.IdentityCache = true
+
+	for i := 0; i < 10; i++ {
+		New(L, &StructTestPerson{})
+	}
+	if n := config.identity.len(); n != 10 {
+		t.Fatalf("expecting 10 cached values, got %d", n)
+	}
+
+	for i := 0; i < 100 && config.identity.len() > 0; i++ {
+		runtime.GC()
+		runtime.Gosched()
+	}
+	if n := config.identity.len(); n != 0 {
+		t.Fatalf("expecting unreachable values to be removed, %d remain", n)
+	}
+}
@@ -2,6 +2,7 @@ package luar
 
 import (
 	"fmt"
+	"io"
 	"reflect"
 
 	"github.com/yuin/gopher-lua"
@@ -13,7 +14,8 @@ import (
 // A nil value (untyped, or a nil channel, function, map, pointer, or slice) is
 // converted to lua.LNil.
 //
-// A lua.LValue value is returned without conversion.
+// A lua.LValue value is returned without conversion. A *Table is converted to
+// the table it views.
 //
 // Boolean values are converted to lua.LBool.
 //
@@ -26,6 +28,10 @@ import (
 // and Go return values converted to Lua values using the rules described by
 // New.
 //
+// Functions marked with Blocking are converted to Lua functions that yield
+// to a Scheduler when called by one of its coroutines. A *Future is converted
+// to a userdata with await, ready, and cancel methods (see Future).
+//
 // If a function has the signature:
 //  func(*LState) int // *LState defined in this package, not in lua
 // The argument and return value conversions described above are skipped, and
@@ -38,7 +44,13 @@ import (
 // is set to a table generated for value's type. The type's method set is
 // callable from the Lua type. If the type implements the fmt.Stringer
 // interface, that method will be used when the value is passed to the Lua
-// tostring function.
+// tostring function. Otherwise, if the type implements the error interface,
+// its Error method is used.
+//
+// Types that implement the error interface additionally have an Unwrap method
+// (returning the result of errors.Unwrap) and an is method (err:is(target)
+// returns the result of errors.Is), unless the type defines methods with
+// those names.
 //
 // With arrays, the # operator returns the array's length. Array elements can
 // be accessed with the index operator (array[index]). Calling an array
@@ -53,7 +65,9 @@ import (
 // no arguments reads one element from the channel, returning the value and a
 // boolean indicating if the channel is closed. Calling a channel value with
 // one argument sends the argument to the channel. The channel's unary minus
-// operator closes the channel (_ = -channel).
+// operator closes the channel (_ = -channel). When a coroutine run by a
+// Scheduler sends to or receives from a channel, the coroutine yields until
+// the operation completes.
 //
 // With maps, the # operator returns the number of elements in the map. Map
 // elements can be accessed using the index operator (map[key]) and also set
@@ -79,12 +93,39 @@ import (
 // "Field1" or "field1"). If the tag is "-", the field will not be accessible.
 // Any other tag value makes the field accessible through that name.
 //
+// Values of a named bool, numeric, or string type (e.g. "type Status int")
+// are converted like their underlying type, unless the state's
+// Config.NamedTypes function selects the type. Selected values are converted
+// to *lua.LUserData with the type's method set, and they can be passed to
+// tostring. Values of the same type can be compared using ==, <, and <=.
+// Numeric values support the arithmetic operators, and string values support
+// # and the concatenation operator. Arithmetic is performed using Go
+// semantics (e.g. integer division), and its result has the same named type.
+// A number operand that the type cannot represent exactly (e.g. 2.5 for an
+// integer type) raises an error.
+//
 // Pointer values can be compared for equality. The pointed to value can be
 // changed using the pow operator (pointer = pointer ^ value). A pointer can be
 // dereferenced using the unary minus operator (value = -pointer).
 //
-// All other values (complex numbers, unsafepointer, uintptr) are converted to
-// *lua.LUserData with its Value field set to value and no custom metatable.
+// Complex numbers are converted to *lua.LUserData with its Value field set to
+// value. They support the +, -, *, /, ^, unary minus, and == operators, where
+// the other operand may be a number or a table {re, im}. The real, imag, abs,
+// phase, and conj methods return the number's parts, modulus, argument, and
+// conjugate. Complex numbers can be passed to tostring.
+//
+// Pointers to big.Int, big.Float, and big.Rat values from the math/big
+// package support the +, -, *, /, unary minus, ==, <, and <= operators, where
+// the other operand may be a number or a string. *big.Int values also support
+// the % operator (with the sign of the divisor, as in Lua) and the ^ operator.
+// *big.Float and *big.Rat values do not support ^: unlike other pointers,
+// their pointed to value cannot be set using it. Arithmetic returns a new
+// value rather than modifying an operand, and the unary minus operator
+// negates the value instead of dereferencing the pointer. The values' methods
+// remain accessible.
+//
+// All other values (unsafepointer, uintptr) are converted to *lua.LUserData
+// with its Value field set to value and no custom metatable.
 //
 func New(L *lua.LState, value interface{}) lua.LValue {
 	if value == nil {
@@ -93,8 +134,30 @@ func New(L *lua.LState, value interface{}) lua.LValue {
 	if lval, ok := value.(lua.LValue); ok {
 		return lval
 	}
+	if table, ok := value.(*Table); ok {
+		if table == nil {
+			return lua.LNil
+		}
+		return table.t
+	}
+	if b, ok := value.(blockingFunc); ok {
+		return blockingWrapper(L, b.fn)
+	}
+	if closer, ok := value.(io.Closer); ok {
+		if config := GetConfig(L); config.TrackClosers {
+			trackCloser(L, config, closer)
+		}
+	}
+
+	val := reflect.ValueOf(value)
+	if isPreservedType(L, val.Type()) {
+		ud := L.NewUserData()
+		ud.Value = value
+		ud.Metatable = getMetatable(L, val.Type())
+		return ud
+	}
 
-	switch val := reflect.ValueOf(value); val.Kind() {
+	switch val.Kind() {
 	case reflect.Bool:
 		return lua.LBool(val.Bool())
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
@@ -107,8 +170,11 @@ func New(L *lua.LState, value interface{}) lua.LValue {
 		if val.IsNil() {
 			return lua.LNil
 		}
+		if config := GetConfig(L); config.IdentityCache && identifiable(val) {
+			return identityUserData(L, config, val)
+		}
 		fallthrough
-	case reflect.Array, reflect.Struct:
+	case reflect.Array, reflect.Complex64, reflect.Complex128, reflect.Struct:
 		ud := L.NewUserData()
 		ud.Value = val.Interface()
 		ud.Metatable = getMetatable(L, val.Type())
@@ -141,6 +207,11 @@ func New(L *lua.LState, value interface{}) lua.LValue {
 // length (defaults to 0), and the second argument optionally specifies the
 // slice's capacity (defaults to the first argument). The new slice is returned.
 //
+// If value is a big.Int, big.Float, or big.Rat from the math/big package, the
+// first argument optionally specifies the initial value as a number or string
+// (e.g. "123456789012345678901234567890", "1.5e100", or "1/3"). A pointer to
+// the new value is returned.
+//
 // All other types return a new pointer to the zero value of value's type.
 func NewType(L *lua.LState, value interface{}) lua.LValue {
 	val := reflect.TypeOf(value)
@@ -154,6 +225,9 @@ func NewType(L *lua.LState, value interface{}) lua.LValue {
 type conversionError struct {
 	Lua  lua.LValue
 	Hint reflect.Type
+
+	// Why the value cannot be converted, if not because of its type.
+	Reason string
 }
 
 func (c conversionError) Error() string {
@@ -169,6 +243,9 @@ func (c conversionError) Error() string {
 		val = c.Lua
 	}
 
+	if c.Reason != "" {
+		return fmt.Sprintf("cannot use %v (type %T) as type %s: %s", val, val, c.Hint, c.Reason)
+	}
 	return fmt.Sprintf("cannot use %v (type %T) as type %s", val, val, c.Hint)
 }
 
@@ -182,8 +259,156 @@ func (s structFieldError) Error() string {
 }
 
 func lValueToReflect(L *lua.LState, v lua.LValue, hint reflect.Type, tryConvertPtr *bool) (reflect.Value, error) {
-	visited := make(map[*lua.LTable]reflect.Value)
-	return lValueToReflectInner(L, v, hint, visited, tryConvertPtr)
+	// visited is only needed when converting tables
+	var visited map[*lua.LTable]reflect.Value
+	tbl, ok := v.(*lua.LTable)
+	if !ok {
+		return lValueToReflectInner(L, v, hint, visited, tryConvertPtr)
+	}
+	visited = make(map[*lua.LTable]reflect.Value)
+	val, err := lValueToReflectInner(L, v, hint, visited, tryConvertPtr)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	if err := validate(L, tbl, val); err != nil {
+		return reflect.Value{}, err
+	}
+	return val, nil
+}
+
+// numberToReflect converts n to hint if hint is a predeclared numeric type,
+// avoiding the intermediate values allocated by reflect.Value.Convert. The
+// conversions match those performed by Convert.
+func numberToReflect(n lua.LNumber, hint reflect.Type) (reflect.Value, bool) {
+	if hint.PkgPath() != "" {
+		return reflect.Value{}, false
+	}
+	switch hint.Kind() {
+	case reflect.Int:
+		return reflect.ValueOf(int(int64(n))), true
+	case reflect.Int8:
+		return reflect.ValueOf(int8(int64(n))), true
+	case reflect.Int16:
+		return reflect.ValueOf(int16(int64(n))), true
+	case reflect.Int32:
+		return reflect.ValueOf(int32(int64(n))), true
+	case reflect.Int64:
+		return reflect.ValueOf(int64(n)), true
+	case reflect.Uint:
+		return reflect.ValueOf(uint(uint64(n))), true
+	case reflect.Uint8:
+		return reflect.ValueOf(uint8(uint64(n))), true
+	case reflect.Uint16:
+		return reflect.ValueOf(uint16(uint64(n))), true
+	case reflect.Uint32:
+		return reflect.ValueOf(uint32(uint64(n))), true
+	case reflect.Uint64:
+		return reflect.ValueOf(uint64(n)), true
+	case reflect.Float32:
+		return reflect.ValueOf(float32(n)), true
+	case reflect.Float64:
+		return reflect.ValueOf(float64(n)), true
+	}
+	return reflect.Value{}, false
+}
+
+// lFunctionToReflect converts a Lua function to a Go function of type hint.
+// It is kept separate from lValueToReflectInner so that the variables
+// captured by the Go function are only allocated when converting functions.
+func lFunctionToReflect(L *lua.LState, converted *lua.LFunction, hint reflect.Type) (reflect.Value, error) {
+	emptyIfaceHint := false
+	switch {
+	case hint == refTypeEmptyIface:
+		emptyIfaceHint = true
+		inOut := []reflect.Type{
+			reflect.SliceOf(refTypeEmptyIface),
+		}
+		hint = reflect.FuncOf(inOut, inOut, true)
+	case hint.Kind() != reflect.Func:
+		return reflect.Value{}, conversionError{
+			Lua:  converted,
+			Hint: hint,
+		}
+	}
+
+	lenient := GetConfig(L).LenientFuncs
+	errorOut := lenient && hint.NumOut() > 0 && hint.Out(hint.NumOut()-1) == refTypeError
+
+	fn := func(args []reflect.Value) []reflect.Value {
+		config := GetConfig(L)
+		t := getThread(L, config)
+		defer func() {
+			if rcv := recover(); rcv != nil {
+				// the thread's call stack may not have been unwound
+				t.release()
+				panic(rcv)
+			}
+			putThread(config, t)
+		}()
+		thread := t.LState
+		thread.Push(converted)
+
+		argCount := 0
+		for i, arg := range args {
+			if i+1 == len(args) && hint.IsVariadic() {
+				// arg is a varadic slice
+				for j := 0; j < arg.Len(); j++ {
+					arg := arg.Index(j)
+					thread.Push(New(thread, arg.Interface()))
+					argCount++
+				}
+				break
+			}
+
+			thread.Push(New(thread, arg.Interface()))
+			argCount++
+		}
+
+		if errorOut {
+			if err := thread.PCall(argCount, lua.MultRet, nil); err != nil {
+				return funcErrorResults(hint, UnwrapError(err))
+			}
+		} else {
+			thread.Call(argCount, lua.MultRet)
+		}
+		top := thread.GetTop()
+
+		switch {
+		case emptyIfaceHint:
+			ret := reflect.MakeSlice(reflect.SliceOf(refTypeEmptyIface), top, top)
+
+			for i := 1; i <= top; i++ {
+				item, err := lValueToReflect(thread, thread.Get(i), refTypeEmptyIface, nil)
+				if err != nil {
+					panic(err)
+				}
+				ret.Index(i - 1).Set(item)
+			}
+
+			return []reflect.Value{ret}
+
+		case lenient:
+			return lenientFuncResults(thread, hint, top, errorOut)
+
+		case top == hint.NumOut():
+			ret := make([]reflect.Value, top)
+
+			var err error
+			for i := 1; i <= top; i++ {
+				outHint := hint.Out(i - 1)
+				item := thread.Get(i)
+				ret[i-1], err = lValueToReflect(thread, item, outHint, nil)
+				if err != nil {
+					panic(err)
+				}
+			}
+
+			return ret
+		}
+
+		panic(fmt.Errorf("expecting %d return values, got %d", hint.NumOut(), top))
+	}
+	return reflect.MakeFunc(hint, fn), nil
 }
 
 func lValueToReflectInner(L *lua.LState, v lua.LValue, hint reflect.Type, visited map[*lua.LTable]reflect.Value, tryConvertPtr *bool) (reflect.Value, error) {
@@ -213,91 +438,48 @@ func lValueToReflectInner(L *lua.LState, v lua.LValue, hint reflect.Type, visite
 		}
 		return val.Convert(hint), nil
 	case lua.LNumber:
-		val := reflect.ValueOf(float64(converted))
-		if !val.Type().ConvertibleTo(hint) {
-			return reflect.Value{}, conversionError{
-				Lua:  v,
-				Hint: hint,
-			}
-		}
-		return val.Convert(hint), nil
-	case *lua.LFunction:
-		emptyIfaceHint := false
-		switch {
-		case hint == refTypeEmptyIface:
-			emptyIfaceHint = true
-			inOut := []reflect.Type{
-				reflect.SliceOf(refTypeEmptyIface),
+		if isBigType(hint) {
+			if val, ok := bigFromLValue(v, hint); ok {
+				return val, nil
 			}
-			hint = reflect.FuncOf(inOut, inOut, true)
-		case hint.Kind() != reflect.Func:
 			return reflect.Value{}, conversionError{
 				Lua:  v,
 				Hint: hint,
 			}
 		}
-
-		fn := func(args []reflect.Value) []reflect.Value {
-			thread, cancelFunc := L.NewThread()
-			defer thread.Close()
-			if cancelFunc != nil {
-				defer cancelFunc()
-			}
-			thread.Push(converted)
-			defer thread.SetTop(0)
-
-			argCount := 0
-			for i, arg := range args {
-				if i+1 == len(args) && hint.IsVariadic() {
-					// arg is a varadic slice
-					for j := 0; j < arg.Len(); j++ {
-						arg := arg.Index(j)
-						thread.Push(New(thread, arg.Interface()))
-						argCount++
+		if k := hint.Kind(); k == reflect.Complex64 || k == reflect.Complex128 {
+			return reflect.ValueOf(complex(float64(converted), 0)).Convert(hint), nil
+		}
+		if k := hint.Kind(); isNumericKind(k) || k == reflect.String {
+			switch policy := GetConfig(L).Conversion; {
+			case policy == ConvertStrict && k != reflect.String:
+				if reason := numberLoss(converted, hint); reason != "" {
+					return reflect.Value{}, conversionError{
+						Lua:    v,
+						Hint:   hint,
+						Reason: reason,
 					}
-					break
 				}
-
-				thread.Push(New(thread, arg.Interface()))
-				argCount++
+			case policy == ConvertLenient && k == reflect.String:
+				return reflect.ValueOf(converted.String()).Convert(hint), nil
 			}
-
-			thread.Call(argCount, lua.MultRet)
-			top := thread.GetTop()
-
-			switch {
-			case emptyIfaceHint:
-				ret := reflect.MakeSlice(reflect.SliceOf(refTypeEmptyIface), top, top)
-
-				for i := 1; i <= top; i++ {
-					item, err := lValueToReflect(thread, thread.Get(i), refTypeEmptyIface, nil)
-					if err != nil {
-						panic(err)
-					}
-					ret.Index(i - 1).Set(item)
-				}
-
-				return []reflect.Value{ret}
-
-			case top == hint.NumOut():
-				ret := make([]reflect.Value, top)
-
-				var err error
-				for i := 1; i <= top; i++ {
-					outHint := hint.Out(i - 1)
-					item := thread.Get(i)
-					ret[i-1], err = lValueToReflect(thread, item, outHint, nil)
-					if err != nil {
-						panic(err)
-					}
-				}
-
-				return ret
+		}
+		if val, ok := numberToReflect(converted, hint); ok {
+			return val, nil
+		}
+		if hint == refTypeEmptyIface && GetConfig(L).IdiomaticUntyped {
+			return reflect.ValueOf(untypedNumber(converted)).Convert(hint), nil
+		}
+		val := reflect.ValueOf(float64(converted))
+		if !val.Type().ConvertibleTo(hint) {
+			return reflect.Value{}, conversionError{
+				Lua:  v,
+				Hint: hint,
 			}
-
-			panic(fmt.Errorf("expecting %d return values, got %d", hint.NumOut(), top))
 		}
-		return reflect.MakeFunc(hint, fn), nil
+		return val.Convert(hint), nil
+	case *lua.LFunction:
+		return lFunctionToReflect(L, converted, hint)
 	case *lua.LNilType:
 		switch hint.Kind() {
 		case reflect.Chan, reflect.Func, reflect.Interface, reflect.Map, reflect.Ptr, reflect.Slice, reflect.UnsafePointer, reflect.Uintptr:
@@ -320,6 +502,26 @@ func lValueToReflectInner(L *lua.LState, v lua.LValue, hint reflect.Type, visite
 		return val.Convert(hint), nil
 
 	case lua.LString:
+		if isBigType(hint) {
+			if val, ok := bigFromLValue(v, hint); ok {
+				return val, nil
+			}
+			return reflect.Value{}, conversionError{
+				Lua:  v,
+				Hint: hint,
+			}
+		}
+		if isNumericKind(hint.Kind()) && GetConfig(L).Conversion == ConvertLenient {
+			n, ok := parseNumber(string(converted))
+			if !ok {
+				return reflect.Value{}, conversionError{
+					Lua:    v,
+					Hint:   hint,
+					Reason: "not a number",
+				}
+			}
+			return lValueToReflectInner(L, n, hint, visited, tryConvertPtr)
+		}
 		val := reflect.ValueOf(string(converted))
 		if !val.Type().ConvertibleTo(hint) {
 			return reflect.Value{}, conversionError{
@@ -330,15 +532,40 @@ func lValueToReflectInner(L *lua.LState, v lua.LValue, hint reflect.Type, visite
 		return val.Convert(hint), nil
 
 	case *lua.LTable:
+		if hint == refTypeTable {
+			return reflect.ValueOf(NewTable(L, converted)), nil
+		}
 		if existing := visited[converted]; existing.IsValid() {
 			return existing, nil
 		}
 
 		if hint == refTypeEmptyIface {
+			if config := GetConfig(L); config.IdiomaticUntyped {
+				t := untypedTableType(config, converted)
+				if t == nil {
+					return reflect.Zero(hint), nil
+				}
+				val, err := lValueToReflectInner(L, v, t, visited, nil)
+				if err != nil {
+					return reflect.Value{}, err
+				}
+				return val.Convert(hint), nil
+			}
 			hint = reflect.MapOf(refTypeEmptyIface, refTypeEmptyIface)
 		}
 
 		switch {
+		case hint.Kind() == reflect.Complex64 || hint.Kind() == reflect.Complex128:
+			re, ok1 := converted.RawGetInt(1).(lua.LNumber)
+			im, ok2 := converted.RawGetInt(2).(lua.LNumber)
+			if !ok1 || !ok2 || converted.Len() != 2 {
+				return reflect.Value{}, conversionError{
+					Lua:  v,
+					Hint: hint,
+				}
+			}
+			return reflect.ValueOf(complex(float64(re), float64(im))).Convert(hint), nil
+
 		case hint.Kind() == reflect.Array:
 			elemType := hint.Elem()
 			length := converted.Len()
@@ -412,6 +639,7 @@ func lValueToReflectInner(L *lua.LState, v lua.LValue, hint reflect.Type, visite
 		case hint.Kind() == reflect.Struct:
 			s := reflect.New(hint)
 			visited[converted] = s
+			setDefaults(s)
 
 			t := s.Elem()
 
@@ -419,31 +647,29 @@ func lValueToReflectInner(L *lua.LState, v lua.LValue, hint reflect.Type, visite
 				LTable: getMetatable(L, hint),
 			}
 
+			config := GetConfig(L)
 			for key := lua.LNil; ; {
 				var value lua.LValue
 				key, value = converted.Next(key)
 				if key == lua.LNil {
 					break
 				}
-				if _, ok := key.(lua.LString); !ok {
-					continue
+				var accessor *fieldAccessor
+				if fieldName, ok := key.(lua.LString); ok {
+					accessor = mt.field(string(fieldName))
 				}
-
-				fieldName := key.String()
-				index := mt.fieldIndex(fieldName)
-				if index == nil {
-					return reflect.Value{}, structFieldError{
-						Type:  hint,
-						Field: fieldName,
+				if accessor == nil {
+					if err := unknownKey(L, config, t, key, value, visited); err != nil {
+						return reflect.Value{}, err
 					}
+					continue
 				}
-				field := hint.FieldByIndex(index)
 
-				lValue, err := lValueToReflectInner(L, value, field.Type, visited, nil)
+				lValue, err := lValueToReflectInner(L, value, accessor.typ, visited, nil)
 				if err != nil {
 					return reflect.Value{}, err
 				}
-				t.FieldByIndex(field.Index).Set(lValue)
+				accessor.field(t).Set(lValue)
 			}
 
 			if isPtr {
@@ -1,6 +1,7 @@
 package luar
 
 import (
+	"math/cmplx"
 	"reflect"
 	"strings"
 	"testing"
@@ -316,3 +317,41 @@ func Test_tostringfallback(t *testing.T) {
 		t.Fatalf("invalid tostring %#v\n", out)
 	}
 }
+
+func Test_luar_complex_arith(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	L.SetGlobal("a", New(L, complex(float64(1), float64(2))))
+	L.SetGlobal("b", New(L, complex64(complex(3, -1))))
+
+	testReturn(t, L, `return tostring(a)`, "(1+2i)")
+	testReturn(t, L, `return tostring(a + 1), tostring(2 * a)`, "(2+2i)", "(2+4i)")
+	testReturn(t, L, `return tostring(a - {1, 1}), tostring(-a)`, "(0+1i)", "(-1-2i)")
+	testReturn(t, L, `return tostring(a * a), tostring(a / a)`, "(-3+4i)", "(1+0i)")
+	testReturn(t, L, `return a:real(), a:imag(), tostring(a:conj())`, "1", "2", "(1-2i)")
+	testReturn(t, L, `return (a * a):abs(), b:phase() < 0`, "5", "true")
+	testReturn(t, L, `return a == a + 0, a == a:conj()`, "true", "false")
+	testReturn(t, L, `return tostring(b * {0, 1})`, "(1+3i)")
+
+	var got complex128
+	L.SetGlobal("set", New(L, func(c complex128) {
+		got = c
+	}))
+	// the result is checked in Go: arithmetic on Lua numbers with a
+	// fractional part trips checkptr in gopher-lua's number allocator when
+	// testing with -race
+	testReturn(t, L, `set(a ^ 2)`)
+	if cmplx.Abs(got-complex(-3, 4)) > 1e-9 {
+		t.Fatalf("expecting (-3+4i), got %v", got)
+	}
+	testReturn(t, L, `set({4, 5})`)
+	if got != complex(4, 5) {
+		t.Fatalf("expecting (4+5i), got %v", got)
+	}
+	testReturn(t, L, `set(7)`)
+	if got != complex(7, 0) {
+		t.Fatalf("expecting (7+0i), got %v", got)
+	}
+	testError(t, L, `set({1, 2, 3})`, "cannot use")
+}
@@ -18,6 +18,6 @@ func mapCall(L *lua.LState) int {
 		i++
 		return 2
 	}
-	L.Push(L.NewFunction(fn))
+	L.Push(newFunction(L, fn))
 	return 1
 }
@@ -0,0 +1,279 @@
+package luar
+
+import (
+	"errors"
+	"reflect"
+
+	"github.com/yuin/gopher-lua"
+)
+
+// SliceMergePolicy defines how a table is merged into a slice (see Merge).
+type SliceMergePolicy int
+
+const (
+	// SliceReplace replaces the slice with one converted from the table.
+	SliceReplace SliceMergePolicy = iota
+
+	// SliceAppend appends the values converted from the table's sequence to
+	// the slice.
+	SliceAppend
+
+	// SliceMergeElements merges the n values of the table's sequence into
+	// the first n elements of the slice, growing it if it is shorter.
+	// Elements past n are kept.
+	SliceMergeElements
+)
+
+// MapMergePolicy defines how a table is merged into a map (see Merge).
+type MapMergePolicy int
+
+const (
+	// MapMergeKeys merges the values of the table into the values of the
+	// map with the same keys, adding the keys that the map does not have.
+	// Keys not in the table are kept.
+	MapMergeKeys MapMergePolicy = iota
+
+	// MapReplace replaces the map with one converted from the table.
+	MapReplace
+)
+
+// Merge overlays tbl onto the value pointed to by ptr, changing only what tbl
+// mentions. Unlike converting a table (e.g. when it is passed to a Go
+// function), which starts from a zero value, fields and elements that are not
+// in tbl keep their current values.
+//
+// For each string key of tbl, the value is merged into the struct field of
+// that name, as chosen by Config.FieldNames (or Config.Registry); other keys
+// are handled according to Config.UnknownKeys. Tables are merged recursively into
+// fields that are structs, struct pointers (allocating the struct if the
+// pointer is nil), maps, and slices; other values replace the field's value,
+// converted like a Go function argument. Maps and slices are merged according
+// to Config.MergeMaps and Config.MergeSlices, and arrays element by element
+// if Config.MergeSlices is SliceMergeElements (they are replaced otherwise).
+//
+// A Lua nil value cannot be stored in a table, so Merge cannot reset a field
+// to its zero value. tbl is merged into a copy of the value, which is stored
+// only if merging and validation succeed. The copy is shallow: if an error is
+// returned by Merge (or raised by an assignment when Config.MergeAssign is
+// set), the structs, maps, and slice elements that the value refers to may
+// have been partially updated.
+func Merge(L *lua.LState, tbl *lua.LTable, ptr interface{}) error {
+	ref := reflect.ValueOf(ptr)
+	if ref.Kind() != reflect.Ptr || ref.IsNil() {
+		return errors.New("luar: Merge expects a non-nil pointer")
+	}
+	return mergeValue(L, tbl, ref.Elem())
+}
+
+// mergeValue merges tbl into a copy of dst, validates the copy, and stores it
+// in dst if both succeed.
+func mergeValue(L *lua.LState, tbl *lua.LTable, dst reflect.Value) error {
+	merged := reflect.New(dst.Type()).Elem()
+	merged.Set(dst)
+	if err := newMerger(L).merge(tbl, merged); err != nil {
+		return err
+	}
+	if err := validate(L, tbl, merged); err != nil {
+		return err
+	}
+	dst.Set(merged)
+	return nil
+}
+
+type merger struct {
+	l      *lua.LState
+	config *Config
+	// tables being merged, so that a table containing itself is detected
+	merging map[*lua.LTable]bool
+}
+
+func newMerger(L *lua.LState) *merger {
+	return &merger{
+		l:       L,
+		config:  GetConfig(L),
+		merging: make(map[*lua.LTable]bool),
+	}
+}
+
+// mergeable returns true if tables are merged into values of type t, rather
+// than replacing them.
+func mergeable(t reflect.Type) bool {
+	if t == refTypeTable || t.Implements(refTypeLuaLValue) || isBigType(t) {
+		return false
+	}
+	switch t.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+		return true
+	case reflect.Ptr:
+		return t.Elem().Kind() == reflect.Struct
+	}
+	return false
+}
+
+// merge merges lv into dst, which must be settable.
+func (m *merger) merge(lv lua.LValue, dst reflect.Value) error {
+	tbl, ok := lv.(*lua.LTable)
+	if !ok || !mergeable(dst.Type()) {
+		return m.set(lv, dst)
+	}
+	if m.merging[tbl] {
+		return errors.New("cannot merge a table that contains itself")
+	}
+	m.merging[tbl] = true
+	defer delete(m.merging, tbl)
+
+	switch dst.Kind() {
+	case reflect.Ptr:
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+			setDefaults(dst)
+		}
+		return m.mergeStruct(tbl, dst.Elem())
+	case reflect.Struct:
+		return m.mergeStruct(tbl, dst)
+	case reflect.Map:
+		if m.config.MergeMaps == MapReplace {
+			return m.set(tbl, dst)
+		}
+		return m.mergeMap(tbl, dst)
+	case reflect.Slice:
+		switch m.config.MergeSlices {
+		case SliceAppend:
+			return m.appendSlice(tbl, dst)
+		case SliceMergeElements:
+			return m.mergeElements(tbl, dst)
+		}
+		return m.set(tbl, dst)
+	default: // reflect.Array
+		if m.config.MergeSlices != SliceMergeElements {
+			return m.set(tbl, dst)
+		}
+		return m.mergeElements(tbl, dst)
+	}
+}
+
+// convert converts lv to hint like lValueToReflect, without validating the
+// value, which is done once the whole table is merged.
+func (m *merger) convert(lv lua.LValue, hint reflect.Type) (reflect.Value, error) {
+	return lValueToReflectInner(m.l, lv, hint, make(map[*lua.LTable]reflect.Value), nil)
+}
+
+// set replaces the value of dst with lv.
+func (m *merger) set(lv lua.LValue, dst reflect.Value) error {
+	val, err := m.convert(lv, dst.Type())
+	if err != nil {
+		return err
+	}
+	dst.Set(val)
+	return nil
+}
+
+func (m *merger) mergeStruct(tbl *lua.LTable, dst reflect.Value) error {
+	mt := &Metatable{
+		LTable: getMetatable(m.l, dst.Type()),
+	}
+
+	for key, value := tbl.Next(lua.LNil); key != lua.LNil; key, value = tbl.Next(key) {
+		fieldName, ok := key.(lua.LString)
+		var accessor *fieldAccessor
+		if ok {
+			accessor = mt.field(string(fieldName))
+		}
+		if accessor == nil {
+			if err := unknownKey(m.l, m.config, dst, key, value, make(map[*lua.LTable]reflect.Value)); err != nil {
+				return err
+			}
+			continue
+		}
+		field := mergeField(dst, accessor.index)
+		if !field.CanSet() {
+			return errors.New("cannot set field " + string(fieldName))
+		}
+		if err := m.merge(value, field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeField returns the field of the struct dst with the given index,
+// allocating (and setting the defaults of) the nil embedded struct pointers
+// it is promoted through.
+func mergeField(dst reflect.Value, index []int) reflect.Value {
+	for i, fieldIndex := range index {
+		if i > 0 && dst.Kind() == reflect.Ptr {
+			if dst.IsNil() {
+				if !dst.CanSet() {
+					return dst
+				}
+				dst.Set(reflect.New(dst.Type().Elem()))
+				setDefaults(dst)
+			}
+			dst = dst.Elem()
+		}
+		dst = dst.Field(fieldIndex)
+	}
+	return dst
+}
+
+func (m *merger) mergeMap(tbl *lua.LTable, dst reflect.Value) error {
+	t := dst.Type()
+	if dst.IsNil() {
+		dst.Set(reflect.MakeMap(t))
+	}
+
+	for key, value := tbl.Next(lua.LNil); key != lua.LNil; key, value = tbl.Next(key) {
+		k, err := m.convert(key, t.Key())
+		if err != nil {
+			return err
+		}
+		// map values are not addressable, so the current value is merged
+		// into a copy
+		elem := reflect.New(t.Elem()).Elem()
+		if current := dst.MapIndex(k); current.IsValid() {
+			elem.Set(current)
+		}
+		if err := m.merge(value, elem); err != nil {
+			return err
+		}
+		dst.SetMapIndex(k, elem)
+	}
+	return nil
+}
+
+func (m *merger) appendSlice(tbl *lua.LTable, dst reflect.Value) error {
+	s := dst
+	for i, length := 1, tbl.Len(); i <= length; i++ {
+		elem, err := m.convert(tbl.RawGetInt(i), s.Type().Elem())
+		if err != nil {
+			return err
+		}
+		s = reflect.Append(s, elem)
+	}
+	dst.Set(s)
+	return nil
+}
+
+// mergeElements merges the sequence of tbl into the slice or array dst.
+func (m *merger) mergeElements(tbl *lua.LTable, dst reflect.Value) error {
+	length := tbl.Len()
+	if length > dst.Len() {
+		if dst.Kind() == reflect.Array {
+			return conversionError{
+				Lua:    tbl,
+				Hint:   dst.Type(),
+				Reason: "too many elements",
+			}
+		}
+		s := reflect.MakeSlice(dst.Type(), length, length)
+		reflect.Copy(s, dst)
+		dst.Set(s)
+	}
+
+	for i := 0; i < length; i++ {
+		if err := m.merge(tbl.RawGetInt(i+1), dst.Index(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,222 @@
+package luar
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/yuin/gopher-lua"
+)
+
+type mergeServer struct {
+	Host string
+	Port int
+}
+
+type MergeTestLimits struct {
+	Burst int
+}
+
+type mergeConfig struct {
+	Name    string
+	Server  mergeServer
+	Backup  *mergeServer
+	Tags    []string
+	Servers []mergeServer
+	Labels  map[string]string
+	Zones   map[string]mergeServer
+	Ports   [3]int
+	*MergeTestLimits
+}
+
+func newMergeConfig() *mergeConfig {
+	return &mergeConfig{
+		Name:    "prod",
+		Server:  mergeServer{Host: "example.com", Port: 80},
+		Tags:    []string{"a", "b"},
+		Servers: []mergeServer{{Host: "one", Port: 1}, {Host: "two", Port: 2}},
+		Labels:  map[string]string{"team": "core", "tier": "1"},
+		Zones:   map[string]mergeServer{"eu": {Host: "eu.example.com", Port: 443}},
+		Ports:   [3]int{1, 2, 3},
+	}
+}
+
+func mergeTable(t *testing.T, L *lua.LState, code string) *lua.LTable {
+	t.Helper()
+	if err := L.DoString("return " + code); err != nil {
+		t.Fatal(err)
+	}
+	tbl := L.CheckTable(-1)
+	L.Pop(1)
+	return tbl
+}
+
+func Test_merge(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	c := newMergeConfig()
+	tbl := mergeTable(t, L, `{
+		server = {port = 8080},
+		backup = {host = "backup.example.com"},
+		tags = {"c"},
+		labels = {tier = "2", env = "prod"},
+		zones = {eu = {port = 8443}, us = {host = "us.example.com"}},
+		burst = 10,
+	}`)
+	if err := Merge(L, tbl, c); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := newMergeConfig()
+	expected.Server.Port = 8080
+	expected.Backup = &mergeServer{Host: "backup.example.com"}
+	expected.Tags = []string{"c"}
+	expected.Labels = map[string]string{"team": "core", "tier": "2", "env": "prod"}
+	expected.Zones = map[string]mergeServer{
+		"eu": {Host: "eu.example.com", Port: 8443},
+		"us": {Host: "us.example.com"},
+	}
+	expected.MergeTestLimits = &MergeTestLimits{Burst: 10}
+	if !reflect.DeepEqual(c, expected) {
+		t.Fatalf("expecting %#v, got %#v", expected, c)
+	}
+
+	// existing struct pointers are merged in place
+	backup := c.Backup
+	if err := Merge(L, mergeTable(t, L, `{backup = {port = 22}}`), c); err != nil {
+		t.Fatal(err)
+	}
+	if c.Backup != backup || *backup != (mergeServer{Host: "backup.example.com", Port: 22}) {
+		t.Fatalf("unexpected backup %#v", c.Backup)
+	}
+}
+
+func Test_merge_slices(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+	config := GetConfig(L)
+
+	for policy, expected := range map[SliceMergePolicy]*mergeConfig{
+		SliceAppend: func() *mergeConfig {
+			c := newMergeConfig()
+			c.Tags = []string{"a", "b", "c"}
+			c.Servers = append(c.Servers, mergeServer{Port: 10})
+			c.Ports = [3]int{7, 2, 3}
+			return c
+		}(),
+		SliceMergeElements: func() *mergeConfig {
+			c := newMergeConfig()
+			c.Tags = []string{"c", "b"}
+			c.Servers[0].Port = 10
+			c.Ports = [3]int{7, 2, 3}
+			return c
+		}(),
+	} {
+		config.MergeSlices = policy
+		c := newMergeConfig()
+		tbl := mergeTable(t, L, `{tags = {"c"}, servers = {{port = 10}}, ports = {7}}`)
+		if policy == SliceAppend {
+			// arrays are replaced, so a table of the array's length is
+			// needed
+			tbl.RawSetString("ports", mergeTable(t, L, `{7, 2, 3}`))
+		}
+		if err := Merge(L, tbl, c); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(c, expected) {
+			t.Fatalf("%d: expecting %#v, got %#v", policy, expected, c)
+		}
+	}
+
+	config.MergeSlices = SliceMergeElements
+	c := newMergeConfig()
+	if err := Merge(L, mergeTable(t, L, `{servers = {{}, {}, {host = "three"}}}`), c); err != nil {
+		t.Fatal(err)
+	}
+	expected := newMergeConfig().Servers
+	expected = append(expected, mergeServer{Host: "three"})
+	if !reflect.DeepEqual(c.Servers, expected) {
+		t.Fatalf("expecting %#v, got %#v", expected, c.Servers)
+	}
+
+	if err := Merge(L, mergeTable(t, L, `{ports = {1, 2, 3, 4}}`), c); err == nil {
+		t.Fatal("expecting error merging too many elements into an array")
+	}
+}
+
+func Test_merge_maps(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+	GetConfig(L).MergeMaps = MapReplace
+
+	c := newMergeConfig()
+	if err := Merge(L, mergeTable(t, L, `{labels = {env = "dev"}}`), c); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"env": "dev"}
+	if !reflect.DeepEqual(c.Labels, expected) {
+		t.Fatalf("expecting %#v, got %#v", expected, c.Labels)
+	}
+
+	// nil maps are created when merging keys
+	GetConfig(L).MergeMaps = MapMergeKeys
+	c.Labels = nil
+	if err := Merge(L, mergeTable(t, L, `{labels = {env = "dev"}}`), c); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(c.Labels, expected) {
+		t.Fatalf("expecting %#v, got %#v", expected, c.Labels)
+	}
+}
+
+func Test_merge_errors(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	var c mergeConfig
+	if err := Merge(L, L.NewTable(), c); err == nil {
+		t.Fatal("expecting error for non-pointer")
+	}
+	if err := Merge(L, L.NewTable(), (*mergeConfig)(nil)); err == nil {
+		t.Fatal("expecting error for nil pointer")
+	}
+
+	err := Merge(L, mergeTable(t, L, `{server = {hostname = "x"}}`), &c)
+	if err == nil || err.Error() != "type luar.mergeServer has no field hostname" {
+		t.Fatalf("unexpected error %v", err)
+	}
+	c.Name = "prod"
+	err = Merge(L, mergeTable(t, L, `{name = "staging", server = {host = "x", port = "x"}}`), &c)
+	if err == nil {
+		t.Fatal("expecting conversion error")
+	}
+	if c.Name != "prod" || c.Server != (mergeServer{}) {
+		t.Fatalf("expecting a failed merge to leave the value unchanged, got %#v", c)
+	}
+
+	tbl := mergeTable(t, L, `{}`)
+	tbl.RawSetString("backup", tbl)
+	type node struct {
+		Backup *node
+	}
+	if err := Merge(L, tbl, &node{}); err == nil {
+		t.Fatal("expecting error for a table containing itself")
+	}
+}
+
+func Test_merge_assign(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	c := newMergeConfig()
+	L.SetGlobal("c", New(L, c))
+
+	testReturn(t, L, `c.server = {port = 8080}; return c.server.host, c.server.port`, "", "8080")
+
+	GetConfig(L).MergeAssign = true
+	c.Server.Host = "example.com"
+	testReturn(t, L, `c.server = {port = 9090}; return c.server.host, c.server.port`, "example.com", "9090")
+	testReturn(t, L, `c.labels = {env = "dev"}; return c.labels.team, c.labels.env`, "core", "dev")
+	testReturn(t, L, `c.name = "staging"; return c.name`, "staging")
+	testError(t, L, `c.server = {hostname = "x"}`, "has no field hostname")
+}
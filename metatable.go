@@ -19,10 +19,16 @@ func MT(L *lua.LState, value interface{}) *Metatable {
 	}
 
 	switch typ := reflect.TypeOf(value); typ.Kind() {
-	case reflect.Array, reflect.Chan, reflect.Map, reflect.Ptr, reflect.Slice, reflect.Struct:
+	case reflect.Array, reflect.Chan, reflect.Complex64, reflect.Complex128, reflect.Map, reflect.Ptr, reflect.Slice, reflect.Struct:
 		return &Metatable{
 			LTable: getMetatable(L, typ),
 		}
+	default:
+		if isPreservedType(L, typ) {
+			return &Metatable{
+				LTable: getMetatable(L, typ),
+			}
+		}
 	}
 	return nil
 }
@@ -35,10 +41,21 @@ func (m *Metatable) method(name string) lua.LValue {
 	return nil
 }
 
-func (m *Metatable) fieldIndex(name string) []int {
+func (m *Metatable) field(name string) *fieldAccessor {
 	fields := m.RawGetString("fields").(*lua.LTable)
-	if index := fields.RawGetString(name); index != lua.LNil {
-		return index.(*lua.LUserData).Value.([]int)
+	if accessor := fields.RawGetString(name); accessor != lua.LNil {
+		return accessor.(*lua.LUserData).Value.(*fieldAccessor)
+	}
+	return nil
+}
+
+// member returns the method (a *lua.LFunction) or field (a *lua.LUserData
+// holding a *fieldAccessor) named name of a struct or struct pointer
+// metatable. Methods take precedence over fields.
+func (m *Metatable) member(name string) lua.LValue {
+	members := m.RawGetString("members").(*lua.LTable)
+	if member := members.RawGetString(name); member != lua.LNil {
+		return member
 	}
 	return nil
 }
@@ -0,0 +1,91 @@
+package luar
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"runtime/debug"
+	"strings"
+
+	"github.com/yuin/gopher-lua"
+)
+
+// maxStackFrames is the maximum number of Go stack frames included in the
+// error raised for a recovered panic.
+const maxStackFrames = 10
+
+var pkgPath = reflect.TypeOf(Config{}).PkgPath()
+
+func funcName(fn reflect.Value) string {
+	if f := runtime.FuncForPC(fn.Pointer()); f != nil {
+		return f.Name()
+	}
+	return fn.Type().String()
+}
+
+// protect returns fn wrapped so that a Go panic raised while it runs is
+// re-raised as a Lua error that includes the name returned by name and a
+// trimmed Go stack trace, unless Config.PropagatePanics is set. Lua errors
+// raised by fn are passed through unchanged.
+func protect(fn lua.LGFunction, name func() string) lua.LGFunction {
+	return func(L *lua.LState) int {
+		defer func() {
+			rcv := recover()
+			if rcv == nil {
+				return
+			}
+			if _, ok := rcv.(*lua.ApiError); ok || GetConfig(L).PropagatePanics {
+				panic(rcv)
+			}
+			L.RaiseError("%s: panic: %v\ngo stack traceback:\n%s", name(), rcv, goStack(debug.Stack()))
+		}()
+		return fn(L)
+	}
+}
+
+// newFunction returns a new Lua function that calls the protected fn.
+func newFunction(L *lua.LState, fn lua.LGFunction) *lua.LFunction {
+	return L.NewFunction(protect(fn, func() string {
+		return funcName(reflect.ValueOf(fn))
+	}))
+}
+
+// goStack returns the frames of stack, the output of debug.Stack called
+// while recovering from a panic, from the panicking function to the luar
+// function called by Lua. Runtime and reflect frames are omitted.
+func goStack(stack []byte) string {
+	lines := strings.Split(strings.TrimSpace(string(stack)), "\n")
+	if len(lines) > 0 {
+		// goroutine header
+		lines = lines[1:]
+	}
+
+	var (
+		b         strings.Builder
+		panicking bool
+		frames    int
+	)
+	for i := 0; i+1 < len(lines); i += 2 {
+		fn, location := lines[i], strings.TrimSpace(lines[i+1])
+		if !panicking {
+			panicking = strings.HasPrefix(fn, "panic(")
+			continue
+		}
+		if strings.HasPrefix(fn, pkgPath+".protect") || strings.HasPrefix(fn, "github.com/yuin/gopher-lua.") || strings.HasPrefix(fn, "created by ") {
+			break
+		}
+		if strings.HasPrefix(fn, "runtime.") || strings.HasPrefix(fn, "reflect.") {
+			continue
+		}
+		if frames == maxStackFrames {
+			b.WriteString("\t...\n")
+			break
+		}
+		if j := strings.LastIndex(location, " +0x"); j >= 0 {
+			location = location[:j]
+		}
+		fmt.Fprintf(&b, "\t%s\n\t\t%s\n", fn, location)
+		frames++
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
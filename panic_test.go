@@ -0,0 +1,73 @@
+package luar
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/yuin/gopher-lua"
+)
+
+func testPanicFunc(n int) int {
+	var m map[string]int
+	if n > 0 {
+		m["x"] = n
+	}
+	return n
+}
+
+func Test_panic_recovered(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	L.SetGlobal("fn", New(L, testPanicFunc))
+
+	testReturn(t, L, `return fn(0)`, "0")
+
+	err := L.DoString(`fn(1)`)
+	if err == nil {
+		t.Fatal("expecting error")
+	}
+	msg := err.Error()
+	for _, expect := range []string{
+		"gopher-luar.testPanicFunc: panic: assignment to entry in nil map",
+		"go stack traceback:",
+		"panic_test.go:",
+	} {
+		if !strings.Contains(msg, expect) {
+			t.Fatalf("error %q does not contain %q", msg, expect)
+		}
+	}
+	if strings.Contains(msg, "runtime/debug") || strings.Contains(msg, "reflect.Value") {
+		t.Fatalf("error %q contains untrimmed stack frames", msg)
+	}
+
+	testReturn(t, L, `return pcall(fn, 1) == false`, "true")
+}
+
+func Test_panic_metamethod(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	L.SetGlobal("s", New(L, TestPtrNested{}))
+
+	testError(t, L, `return s.Value`, "gopher-luar.structIndex: panic:")
+}
+
+func Test_panic_propagate(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	GetConfig(L).PropagatePanics = true
+	L.SetGlobal("fn", New(L, testPanicFunc))
+
+	defer func() {
+		if rcv := recover(); rcv == nil {
+			t.Fatal("expecting panic")
+		} else if _, ok := rcv.(*lua.ApiError); ok {
+			t.Fatalf("expecting Go panic, got %v", rcv)
+		}
+	}()
+	L.Push(L.GetGlobal("fn"))
+	L.Push(lua.LNumber(1))
+	L.Call(1, 0)
+}
@@ -0,0 +1,209 @@
+package luar
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/yuin/gopher-lua"
+)
+
+// Pool is a set of states that are configured once and reused. It is safe for
+// concurrent use.
+//
+// When a state is returned to the pool, the globals and the loaded modules
+// (package.loaded) that were added, removed, or replaced since the state was
+// set up are restored, its stack is cleared, and its context (see
+// (*lua.LState).SetContext) is removed. Changes made within global tables
+// (e.g. string.custom = ...) are not reverted.
+type Pool struct {
+	// The options used to create new states.
+	Options lua.Options
+
+	// If true, states returned to the pool with a non-nil error are closed
+	// rather than reused, and a new state is created in their place when
+	// needed.
+	RebuildOnError bool
+
+	registry *TypeRegistry
+	setup    func(L *lua.LState) error
+
+	mu       sync.Mutex
+	closed   bool
+	idle     []*lua.LState
+	snapshot map[*lua.LState]*poolSnapshot
+}
+
+// poolSnapshot holds copies of the globals and loaded modules of a state once
+// it is set up.
+type poolSnapshot struct {
+	globals *lua.LTable
+	loaded  *lua.LTable
+	// true from the time the state is returned to the pool until it is
+	// obtained again
+	idle bool
+}
+
+// ErrPoolClosed is returned by Pool.Get once the pool is closed.
+var ErrPoolClosed = errors.New("luar: pool is closed")
+
+// NewPool returns a new, empty pool. New states are attached to registry,
+// which may be nil, and then passed to setup (e.g. to set Config options and
+// define globals using New). setup may be nil.
+func NewPool(registry *TypeRegistry, setup func(L *lua.LState) error) *Pool {
+	return &Pool{
+		registry: registry,
+		setup:    setup,
+		snapshot: make(map[*lua.LState]*poolSnapshot),
+	}
+}
+
+// Get returns an idle state from the pool, or creates and sets up a new one
+// if there are none. An error is returned if setup fails, or ErrPoolClosed if
+// the pool is closed.
+func (p *Pool) Get() (*lua.LState, error) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, ErrPoolClosed
+	}
+	if n := len(p.idle); n > 0 {
+		L := p.idle[n-1]
+		p.idle = p.idle[:n-1]
+		p.snapshot[L].idle = false
+		p.mu.Unlock()
+		return L, nil
+	}
+	p.mu.Unlock()
+
+	L := lua.NewState(p.Options)
+	GetConfig(L).Registry = p.registry
+	if p.setup != nil {
+		if err := p.setup(L); err != nil {
+			Close(L)
+			return nil, err
+		}
+	}
+	L.SetTop(0)
+
+	snapshot := &poolSnapshot{
+		globals: copyTable(L, L.G.Global),
+		loaded:  copyTable(L, loadedTable(L)),
+	}
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		Close(L)
+		return nil, ErrPoolClosed
+	}
+	p.snapshot[L] = snapshot
+	p.mu.Unlock()
+	return L, nil
+}
+
+// loadedTable returns the table of loaded modules of L (package.loaded).
+func loadedTable(L *lua.LState) *lua.LTable {
+	loaded, _ := L.GetField(L.Get(lua.RegistryIndex), "_LOADED").(*lua.LTable)
+	return loaded
+}
+
+// copyTable returns a shallow copy of tbl, which may be nil.
+func copyTable(L *lua.LState, tbl *lua.LTable) *lua.LTable {
+	if tbl == nil {
+		return nil
+	}
+	c := L.CreateTable(0, 0)
+	tbl.ForEach(func(key, value lua.LValue) {
+		c.RawSet(key, value)
+	})
+	return c
+}
+
+// restoreTable sets the keys and values of tbl to those of its copy c.
+func restoreTable(tbl, c *lua.LTable) {
+	if tbl == nil || c == nil {
+		return
+	}
+	var added []lua.LValue
+	tbl.ForEach(func(key, value lua.LValue) {
+		if c.RawGet(key) == lua.LNil {
+			added = append(added, key)
+		}
+	})
+	for _, key := range added {
+		tbl.RawSet(key, lua.LNil)
+	}
+	c.ForEach(func(key, value lua.LValue) {
+		tbl.RawSet(key, value)
+	})
+}
+
+// Put returns L, obtained from Get, to the pool. err should be the error, if
+// any, encountered while using L. Resources tracked by L are released (see
+// ReleaseAll), and the first error closing them is returned. If the pool is
+// closed, L is closed. Put panics if L is already in the pool.
+func (p *Pool) Put(L *lua.LState, err error) error {
+	p.mu.Lock()
+	snapshot := p.snapshot[L]
+	if snapshot != nil && snapshot.idle {
+		p.mu.Unlock()
+		panic("luar: state returned to the pool twice")
+	}
+	closed := p.closed
+	if snapshot != nil {
+		snapshot.idle = true
+		if closed || (err != nil && p.RebuildOnError) {
+			delete(p.snapshot, L)
+		}
+	}
+	p.mu.Unlock()
+	if snapshot == nil {
+		panic("luar: state not obtained from this pool")
+	}
+
+	if closed || (err != nil && p.RebuildOnError) {
+		return Close(L)
+	}
+
+	releaseErr := ReleaseAll(L)
+
+	restoreTable(L.G.Global, snapshot.globals)
+	restoreTable(loadedTable(L), snapshot.loaded)
+	L.SetTop(0)
+	L.RemoveContext()
+
+	p.mu.Lock()
+	if p.closed {
+		// the pool was closed while the state was being restored
+		delete(p.snapshot, L)
+		p.mu.Unlock()
+		Close(L)
+		return releaseErr
+	}
+	p.idle = append(p.idle, L)
+	p.mu.Unlock()
+	return releaseErr
+}
+
+// Close closes the idle states of the pool. States that have not been
+// returned to the pool are closed when they are returned. Get returns
+// ErrPoolClosed once the pool is closed. The first error releasing the
+// states' resources is returned.
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	p.closed = true
+	idle := p.idle
+	p.idle = nil
+	for _, L := range idle {
+		delete(p.snapshot, L)
+	}
+	p.mu.Unlock()
+
+	var first error
+	for _, L := range idle {
+		if err := Close(L); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
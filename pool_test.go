@@ -0,0 +1,166 @@
+package luar
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/yuin/gopher-lua"
+)
+
+func Test_pool(t *testing.T) {
+	setups := 0
+	p := NewPool(NewTypeRegistry(nil, nil), func(L *lua.LState) error {
+		setups++
+		L.SetGlobal("greet", New(L, func(name string) string {
+			return "hello " + name
+		}))
+		return nil
+	})
+	defer p.Close()
+
+	L, err := p.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	testReturn(t, L, `leaked = 1; greet = nil; print = nil; return 1`, "1")
+	L.Push(lua.LString("left on stack"))
+	if err := p.Put(L, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	L2, err := p.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if L2 != L {
+		t.Fatal("expecting state to be reused")
+	}
+	if top := L2.GetTop(); top != 0 {
+		t.Fatalf("expecting empty stack, got %d values", top)
+	}
+	testReturn(t, L2, `return leaked, greet("bob"), type(print)`, "nil", "hello bob", "function")
+	if setups != 1 {
+		t.Fatalf("expecting 1 setup, got %d", setups)
+	}
+	p.Put(L2, nil)
+}
+
+func Test_pool_rebuild(t *testing.T) {
+	p := NewPool(nil, nil)
+	p.RebuildOnError = true
+	defer p.Close()
+
+	L, err := p.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Put(L, L.DoString(`error("failed")`))
+
+	L2, err := p.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if L2 == L {
+		t.Fatal("expecting a new state")
+	}
+	p.Put(L2, nil)
+}
+
+func Test_pool_setup_error(t *testing.T) {
+	setupErr := errors.New("setup failed")
+	p := NewPool(nil, func(L *lua.LState) error {
+		return setupErr
+	})
+
+	if _, err := p.Get(); err != setupErr {
+		t.Fatalf("expecting setup error, got %v", err)
+	}
+}
+
+func Test_pool_loaded(t *testing.T) {
+	p := NewPool(nil, func(L *lua.LState) error {
+		L.PreloadModule("counter", func(L *lua.LState) int {
+			L.Push(L.NewTable())
+			return 1
+		})
+		return nil
+	})
+	defer p.Close()
+
+	L, err := p.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	testReturn(t, L, `require("counter").n = 1; package.loaded.custom = true`)
+	p.Put(L, nil)
+
+	L, err = p.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	testReturn(t, L, `return require("counter").n, package.loaded.custom, type(package.loaded.string)`, "nil", "nil", "table")
+	p.Put(L, nil)
+}
+
+func Test_pool_close(t *testing.T) {
+	p := NewPool(nil, func(L *lua.LState) error {
+		GetConfig(L).TrackClosers = true
+		return nil
+	})
+
+	L, err := p.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Get(); err != ErrPoolClosed {
+		t.Fatalf("expecting ErrPoolClosed, got %v", err)
+	}
+
+	// a state checked out while the pool is closed is closed when returned
+	r := &TestResource{}
+	New(L, r)
+	if err := p.Put(L, nil); err != nil {
+		t.Fatal(err)
+	}
+	if r.closed != 1 {
+		t.Fatalf("expecting the state's resources to be released")
+	}
+	if len(p.idle) != 0 || len(p.snapshot) != 0 {
+		t.Fatalf("expecting the state not to be kept by the pool")
+	}
+}
+
+func Test_pool_put(t *testing.T) {
+	p := NewPool(nil, nil)
+	defer p.Close()
+
+	L, err := p.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	L.SetContext(ctx)
+	p.Put(L, nil)
+
+	L, err = p.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	testReturn(t, L, `return 1 + 1`, "2")
+	p.Put(L, nil)
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expecting a state returned twice to panic")
+		}
+		if len(p.idle) != 1 {
+			t.Fatalf("expecting the state to be idle once, got %d idle states", len(p.idle))
+		}
+	}()
+	p.Put(L, nil)
+}
@@ -27,7 +27,9 @@ func ptrIndex(L *lua.LState) int {
 
 	// fallback to non-pointer method
 	ref = ref.Elem()
-	mt = MT(L, ref.Interface())
+	if mt = MT(L, ref.Interface()); mt == nil {
+		return 0
+	}
 	if fn := mt.method(key); fn != nil {
 		L.Push(fn)
 		return 1
@@ -0,0 +1,78 @@
+package luar
+
+import (
+	"reflect"
+	"sync"
+)
+
+// TypeRegistry is a cache of the metadata luar uses to access the fields and
+// methods of Go types, i.e. the result of reflecting over a type and applying
+// the naming functions to its fields and methods. Each entry is immutable
+// once computed.
+//
+// A TypeRegistry is safe for concurrent use, and can be shared by any number
+// of states by setting Config.Registry. Each state then only creates the Lua
+// tables and functions for the types it uses.
+type TypeRegistry struct {
+	fieldNames  func(s reflect.Type, f reflect.StructField) []string
+	methodNames func(t reflect.Type, m reflect.Method) []string
+
+	mu    sync.RWMutex
+	types map[reflect.Type]*typeInfo
+}
+
+type typeInfo struct {
+	fields  []fieldInfo
+	methods []methodInfo
+	// the index of the extra field of a struct type (see UnknownKeysCollect)
+	extra []int
+}
+
+// NewTypeRegistry returns a new, empty registry. fieldNames and methodNames
+// have the same meaning as Config.FieldNames and Config.MethodNames; if nil,
+// the default behaviour is used. They must be safe for concurrent use.
+func NewTypeRegistry(fieldNames func(s reflect.Type, f reflect.StructField) []string, methodNames func(t reflect.Type, m reflect.Method) []string) *TypeRegistry {
+	return &TypeRegistry{
+		fieldNames:  fieldNames,
+		methodNames: methodNames,
+		types:       make(map[reflect.Type]*typeInfo),
+	}
+}
+
+// Register computes the metadata of the types of values, and of pointers to
+// them, so that states do not have to when the types are first used.
+func (r *TypeRegistry) Register(values ...interface{}) {
+	for _, value := range values {
+		t := reflect.TypeOf(value)
+		if t == nil {
+			continue
+		}
+		r.lookup(t)
+		r.lookup(reflect.PtrTo(t))
+	}
+}
+
+func (r *TypeRegistry) lookup(t reflect.Type) *typeInfo {
+	r.mu.RLock()
+	info := r.types[t]
+	r.mu.RUnlock()
+	if info != nil {
+		return info
+	}
+
+	info = &typeInfo{
+		methods: collectMethods(t, r.methodNames),
+	}
+	if t.Kind() == reflect.Struct {
+		info.fields = namedFields(t, r.fieldNames)
+		info.extra = typeExtraField(t)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if existing := r.types[t]; existing != nil {
+		return existing
+	}
+	r.types[t] = info
+	return info
+}
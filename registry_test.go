@@ -0,0 +1,60 @@
+package luar
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/yuin/gopher-lua"
+)
+
+type TestRegistryPerson struct {
+	Name   string
+	Hidden string `luar:"-"`
+}
+
+func (p *TestRegistryPerson) Greet() string {
+	return "hello " + p.Name
+}
+
+func Test_registry(t *testing.T) {
+	calls := 0
+	var mu sync.Mutex
+	registry := NewTypeRegistry(func(s reflect.Type, f reflect.StructField) []string {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		return defaultFieldNames(s, f)
+	}, func(t reflect.Type, m reflect.Method) []string {
+		return []string{strings.ToLower(m.Name)}
+	})
+	registry.Register(TestRegistryPerson{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			L := lua.NewState()
+			defer L.Close()
+			GetConfig(L).Registry = registry
+
+			L.SetGlobal("p", New(L, &TestRegistryPerson{Name: "bob", Hidden: "x"}))
+			if err := L.DoString(`
+				assert(p.Name == "bob" and p.name == "bob")
+				assert(p.Hidden == nil)
+				assert(p:greet() == "hello bob")
+				assert(p.Greet == nil)
+			`); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if calls != 2 {
+		t.Fatalf("expecting field names to be computed once per field, got %d calls", calls)
+	}
+}
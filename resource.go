@@ -0,0 +1,160 @@
+package luar
+
+import (
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/yuin/gopher-lua"
+)
+
+// Resource is an io.Closer that is tracked by a state. See
+// Config.TrackClosers.
+type Resource struct {
+	// The tracked value.
+	Value io.Closer
+	// The position in the Lua source where the value was converted by New
+	// (e.g. "script.lua:12"). Empty if the value was converted while no Lua
+	// function was running.
+	Where string
+}
+
+var refTypeCloser = reflect.TypeOf((*io.Closer)(nil)).Elem()
+
+type resources struct {
+	// list holds the tracked resources in the order they were tracked, with
+	// nil values in place of the untracked ones until it is compacted.
+	list  []Resource
+	index map[io.Closer]int
+}
+
+func trackCloser(L *lua.LState, c *Config, closer io.Closer) {
+	val := reflect.ValueOf(closer)
+	switch val.Kind() {
+	case reflect.Chan, reflect.Func, reflect.Map, reflect.Ptr, reflect.Slice:
+		if val.IsNil() {
+			return
+		}
+	}
+	if !val.Type().Comparable() {
+		return
+	}
+	if c.resources == nil {
+		c.resources = &resources{
+			index: make(map[io.Closer]int),
+		}
+	}
+	if _, ok := c.resources.index[closer]; ok {
+		return
+	}
+	c.resources.index[closer] = len(c.resources.list)
+	c.resources.list = append(c.resources.list, Resource{
+		Value: closer,
+		Where: strings.TrimSuffix(L.Where(1), ":"),
+	})
+}
+
+// Untrack stops tracking value in L, so that it will not be closed by
+// ReleaseAll. Values closed by a script, through their Close method, are
+// untracked automatically; values closed by Go code should be untracked
+// explicitly.
+func Untrack(L *lua.LState, value io.Closer) {
+	r := GetConfig(L).resources
+	if r == nil || value == nil || !reflect.TypeOf(value).Comparable() {
+		return
+	}
+	if i, ok := r.index[value]; ok {
+		r.list[i].Value = nil
+		delete(r.index, value)
+		r.compact()
+	}
+}
+
+// compact removes the untracked resources from list once they make up half of
+// it, so that its length stays proportional to the number of tracked
+// resources.
+func (r *resources) compact() {
+	if len(r.index)*2 > len(r.list) {
+		return
+	}
+	list := r.list[:0]
+	for _, resource := range r.list {
+		if resource.Value != nil {
+			r.index[resource.Value] = len(list)
+			list = append(list, resource)
+		}
+	}
+	for i := len(list); i < len(r.list); i++ {
+		r.list[i] = Resource{}
+	}
+	r.list = list
+}
+
+// closerMethod wraps fn, the Close method of an io.Closer type, so that values
+// closed by scripts are no longer tracked.
+func closerMethod(L *lua.LState, fn *lua.LFunction) *lua.LFunction {
+	inner := fn.GFunction
+	// the wrapped function reads its upvalues, so the wrapper keeps them
+	upvalues := make([]lua.LValue, len(fn.Upvalues))
+	for i, upvalue := range fn.Upvalues {
+		upvalues[i] = upvalue.Value()
+	}
+	return L.NewClosure(func(L *lua.LState) int {
+		n := inner(L)
+		if ud, ok := L.Get(1).(*lua.LUserData); ok {
+			if closer, ok := ud.Value.(io.Closer); ok {
+				Untrack(L, closer)
+			}
+		}
+		return n
+	}, upvalues...)
+}
+
+// Tracked returns the resources tracked by L that have not yet been released
+// or closed by a script, in the order they were first tracked. Calling it
+// before closing the state can be used to report resources that scripts did
+// not close.
+func Tracked(L *lua.LState) []Resource {
+	r := GetConfig(L).resources
+	if r == nil {
+		return nil
+	}
+	tracked := make([]Resource, 0, len(r.index))
+	for _, resource := range r.list {
+		if resource.Value != nil {
+			tracked = append(tracked, resource)
+		}
+	}
+	return tracked
+}
+
+// ReleaseAll closes every resource tracked by L, most recently tracked first,
+// and stops tracking them. All resources are closed even if some fail to
+// close. The first error encountered is returned.
+func ReleaseAll(L *lua.LState) error {
+	config := GetConfig(L)
+	r := config.resources
+	if r == nil {
+		return nil
+	}
+	config.resources = nil
+
+	var first error
+	for i := len(r.list) - 1; i >= 0; i-- {
+		if value := r.list[i].Value; value != nil {
+			if err := value.Close(); err != nil && first == nil {
+				first = err
+			}
+		}
+	}
+	return first
+}
+
+// Close releases the resources tracked by L using ReleaseAll, and then closes
+// L. It must be used in place of L.Close when Config.TrackClosers is set:
+// L.Close does not release the tracked resources.
+func Close(L *lua.LState) error {
+	err := ReleaseAll(L)
+	L.Close()
+	return err
+}
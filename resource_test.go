@@ -0,0 +1,153 @@
+package luar
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/yuin/gopher-lua"
+)
+
+type TestResource struct {
+	Name   string
+	closed int
+	err    error
+}
+
+func (r *TestResource) Close() error {
+	r.closed++
+	return r.err
+}
+
+func Test_resource_disabled(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	r := &TestResource{}
+	New(L, r)
+
+	if err := ReleaseAll(L); err != nil {
+		t.Fatal(err)
+	}
+	if r.closed != 0 {
+		t.Fatalf("expecting resource to be left open")
+	}
+}
+
+func Test_resource(t *testing.T) {
+	L := lua.NewState()
+
+	GetConfig(L).TrackClosers = true
+
+	var opened []*TestResource
+	L.SetGlobal("open", New(L, func(name string) *TestResource {
+		r := &TestResource{Name: name}
+		opened = append(opened, r)
+		return r
+	}))
+
+	testReturn(t, L, `a = open("a")`)
+	testReturn(t, L, `b = open("b")`)
+	testReturn(t, L, `c = open("c")`)
+
+	Untrack(L, opened[1])
+
+	tracked := Tracked(L)
+	if len(tracked) != 2 || tracked[0].Value != opened[0] || tracked[1].Value != opened[2] {
+		t.Fatalf("unexpected tracked resources %#v", tracked)
+	}
+	if tracked[0].Where != "<string>:1" {
+		t.Fatalf("unexpected resource position %q", tracked[0].Where)
+	}
+
+	opened[2].err = errors.New("close failed")
+	if err := Close(L); err == nil || err.Error() != "close failed" {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if opened[0].closed != 1 || opened[1].closed != 0 || opened[2].closed != 1 {
+		t.Fatalf("unexpected close counts %d %d %d", opened[0].closed, opened[1].closed, opened[2].closed)
+	}
+	if len(Tracked(L)) != 0 {
+		t.Fatalf("expecting no tracked resources after release")
+	}
+}
+
+func Test_resource_nil(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	GetConfig(L).TrackClosers = true
+
+	New(L, (*TestResource)(nil))
+	r := &TestResource{}
+	New(L, r)
+	New(L, r)
+
+	if n := len(Tracked(L)); n != 1 {
+		t.Fatalf("expecting 1 tracked resource, got %d", n)
+	}
+	if err := ReleaseAll(L); err != nil {
+		t.Fatal(err)
+	}
+	if r.closed != 1 {
+		t.Fatalf("expecting resource to be closed once, got %d", r.closed)
+	}
+}
+
+func Test_resource_closed_by_script(t *testing.T) {
+	L := lua.NewState()
+	GetConfig(L).TrackClosers = true
+
+	a, b := &TestResource{Name: "a"}, &TestResource{Name: "b"}
+	L.SetGlobal("a", New(L, a))
+	L.SetGlobal("b", New(L, b))
+
+	testReturn(t, L, `a:close()`)
+	tracked := Tracked(L)
+	if len(tracked) != 1 || tracked[0].Value != b {
+		t.Fatalf("unexpected tracked resources %#v", tracked)
+	}
+
+	if err := Close(L); err != nil {
+		t.Fatal(err)
+	}
+	if a.closed != 1 || b.closed != 1 {
+		t.Fatalf("expecting each resource to be closed once, got %d %d", a.closed, b.closed)
+	}
+}
+
+func Test_resource_compact(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+	GetConfig(L).TrackClosers = true
+
+	var kept []*TestResource
+	for i := 0; i < 1000; i++ {
+		r := &TestResource{}
+		New(L, r)
+		if i%10 == 0 {
+			kept = append(kept, r)
+		} else {
+			Untrack(L, r)
+		}
+	}
+
+	if n := len(GetConfig(L).resources.list); n > 2*len(kept)+1 {
+		t.Fatalf("expecting the list to be compacted, got %d entries for %d resources", n, len(kept))
+	}
+	tracked := Tracked(L)
+	if len(tracked) != len(kept) {
+		t.Fatalf("expecting %d tracked resources, got %d", len(kept), len(tracked))
+	}
+	for i, resource := range tracked {
+		if resource.Value != kept[i] {
+			t.Fatalf("unexpected resource at %d", i)
+		}
+	}
+
+	// the index still refers to the compacted positions
+	Untrack(L, kept[50])
+	if tracked := Tracked(L); len(tracked) != len(kept)-1 || tracked[50].Value != kept[51] {
+		t.Fatalf("unexpected tracked resources after untracking")
+	}
+}
@@ -0,0 +1,297 @@
+package luar
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"runtime/debug"
+
+	"github.com/yuin/gopher-lua"
+)
+
+// Scheduler runs Lua functions as coroutines of a state. When such a
+// coroutine calls a Go function marked with Blocking, or receives from a Go
+// channel, the call is performed in a separate goroutine and the coroutine
+// yields to the scheduler. The scheduler resumes other coroutines in the
+// meantime, and resumes the waiting coroutine with the result once the call
+// completes. This allows many coroutines to wait on Go calls concurrently
+// while sharing one state.
+//
+// gopher-lua does not support yielding across pcall and xpcall, so Go calls
+// made within them are performed synchronously, blocking the state.
+//
+// A Scheduler, and the state it uses, must only be used by one goroutine at
+// a time.
+type Scheduler struct {
+	L *lua.LState
+
+	tasks   map[*lua.LState]*schedulerTask
+	ready   []*schedulerTask
+	done    chan *pendingCall
+	pending int
+
+	// entry points of the functions that perform protected calls
+	protected map[uintptr]bool
+}
+
+type schedulerTask struct {
+	thread  *lua.LState
+	cancel  context.CancelFunc
+	fn      *lua.LFunction
+	args    []lua.LValue
+	waiting bool
+}
+
+// pendingCall is a Go call being performed on behalf of a waiting coroutine.
+type pendingCall struct {
+	task *schedulerTask
+	// converts the call's results, or the message of the panic it raised,
+	// to the values the coroutine is resumed with. If an error is returned,
+	// the coroutine is terminated with that error instead.
+	convert func(L *lua.LState, ret []reflect.Value, panicMsg string) ([]lua.LValue, error)
+	ret     []reflect.Value
+	panic   string
+}
+
+// NewScheduler returns a new scheduler for L. It replaces any scheduler
+// previously created for L.
+func NewScheduler(L *lua.LState) *Scheduler {
+	s := &Scheduler{
+		L:         L,
+		tasks:     make(map[*lua.LState]*schedulerTask),
+		done:      make(chan *pendingCall),
+		protected: make(map[uintptr]bool),
+	}
+	for _, name := range []string{"pcall", "xpcall"} {
+		if fn, ok := L.GetGlobal(name).(*lua.LFunction); ok && fn.IsG {
+			s.protected[reflect.ValueOf(fn.GFunction).Pointer()] = true
+		}
+	}
+	GetConfig(L).scheduler = s
+	return s
+}
+
+// Go adds a new coroutine that calls fn with args. The coroutine starts
+// running when Run is called. Go may also be called by coroutines while Run is
+// running.
+func (s *Scheduler) Go(fn *lua.LFunction, args ...lua.LValue) {
+	thread, cancel := s.L.NewThread()
+	t := &schedulerTask{
+		thread: thread,
+		cancel: cancel,
+		fn:     fn,
+		args:   args,
+	}
+	s.tasks[thread] = t
+	s.ready = append(s.ready, t)
+}
+
+// Run runs the scheduler's coroutines until all of them have returned or
+// raised an error. Coroutines that yield using coroutine.yield are resumed
+// again after the other ready coroutines, and the yielded values are
+// discarded.
+//
+// The first error raised by a coroutine is returned once all coroutines have
+// finished.
+func (s *Scheduler) Run() error {
+	var first error
+	for len(s.ready) > 0 || s.pending > 0 {
+		var p *pendingCall
+		select {
+		case p = <-s.done:
+		default:
+			if len(s.ready) == 0 {
+				p = <-s.done
+			}
+		}
+		if p != nil {
+			if err := s.complete(p); err != nil && first == nil {
+				first = err
+			}
+			continue
+		}
+
+		t := s.ready[0]
+		s.ready = s.ready[1:]
+		if err := s.resume(t); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+func (s *Scheduler) complete(p *pendingCall) error {
+	s.pending--
+	p.task.waiting = false
+	args, err := p.convert(p.task.thread, p.ret, p.panic)
+	if err != nil {
+		s.finish(p.task)
+		return err
+	}
+	p.task.args = args
+	s.ready = append(s.ready, p.task)
+	return nil
+}
+
+func (s *Scheduler) finish(t *schedulerTask) {
+	delete(s.tasks, t.thread)
+	if t.cancel != nil {
+		t.cancel()
+	}
+}
+
+func (s *Scheduler) resume(t *schedulerTask) error {
+	st, err, _ := s.L.Resume(t.thread, t.fn, t.args...)
+	t.args = nil
+
+	switch {
+	case err != nil, st == lua.ResumeOK:
+		s.finish(t)
+		return err
+	case !t.waiting:
+		s.ready = append(s.ready, t)
+	}
+	return nil
+}
+
+// canWait reports whether L is a coroutine run by the scheduler that can
+// yield. gopher-lua does not support yielding across protected calls, so
+// Go calls made within pcall or xpcall are performed synchronously.
+func (s *Scheduler) canWait(L *lua.LState) bool {
+	if _, ok := s.tasks[L]; !ok {
+		return false
+	}
+	for level := 0; ; level++ {
+		dbg, ok := L.GetStack(level)
+		if !ok {
+			return true
+		}
+		fn, err := L.GetInfo("f", dbg, lua.LNil)
+		if f, isFn := fn.(*lua.LFunction); err == nil && isFn && f.IsG && s.protected[reflect.ValueOf(f.GFunction).Pointer()] {
+			return false
+		}
+	}
+}
+
+// wait performs call in a new goroutine and yields the coroutine L until
+// call returns. The coroutine is then resumed with the values returned by
+// convert. name is used to describe call if it panics.
+func (s *Scheduler) wait(L *lua.LState, name string, call func() []reflect.Value, convert func(*lua.LState, []reflect.Value, string) ([]lua.LValue, error)) int {
+	t := s.tasks[L]
+	t.waiting = true
+	s.pending++
+
+	p := &pendingCall{
+		task:    t,
+		convert: convert,
+	}
+	where := L.Where(1)
+	go func() {
+		defer func() {
+			if rcv := recover(); rcv != nil {
+				p.panic = fmt.Sprintf("%s %s: panic: %v\ngo stack traceback:\n%s", where, name, rcv, goStack(debug.Stack()))
+			}
+			s.done <- p
+		}()
+		p.ret = call()
+	}()
+	return L.Yield()
+}
+
+// Blocking marks fn, a Go function, as one that may block (e.g. by
+// performing I/O, or sleeping). The returned value must be converted using
+// New.
+//
+// When the converted function is called by a coroutine run by a Scheduler,
+// fn is called in a separate goroutine and the coroutine yields until fn
+// returns. fn must not access the Lua state. Elsewhere, fn is called like any
+// other function.
+func Blocking(fn interface{}) interface{} {
+	val := reflect.ValueOf(fn)
+	if val.Kind() != reflect.Func {
+		panic("luar: Blocking expects a function, got " + val.Kind().String())
+	}
+	return blockingFunc{val}
+}
+
+type blockingFunc struct {
+	fn reflect.Value
+}
+
+// blockingWrapperSource is the Lua function that creates the wrapper of a
+// blocking function. The Go function returns a boolean indicating whether the
+// call succeeded, followed by its results or the error, so that errors can be
+// raised once a waiting coroutine is resumed.
+const blockingWrapperSource = `
+local call = ...
+local function check(ok, ...)
+	if not ok then
+		error((...), 0)
+	end
+	return ...
+end
+return function(...)
+	return check(call(...))
+end
+`
+
+func blockingWrapper(L *lua.LState, fn reflect.Value) lua.LValue {
+	config := GetConfig(L)
+	if config.blocking == nil {
+		wrapper, err := L.LoadString(blockingWrapperSource)
+		if err != nil {
+			panic(err)
+		}
+		config.blocking = wrapper
+	}
+
+	up := L.NewUserData()
+	up.Value = fn
+	call := L.NewClosure(protect(funcBlocking, func() string {
+		return funcName(fn)
+	}), up, lua.LFalse)
+
+	L.Push(config.blocking)
+	L.Push(call)
+	L.Call(1, 1)
+	ret := L.Get(-1)
+	L.Pop(1)
+	return ret
+}
+
+func funcBlocking(L *lua.LState) int {
+	ref, refType := getFunc(L)
+	args, _ := funcArgs(L, refType, nil)
+
+	if s := GetConfig(L).scheduler; s != nil && s.canWait(L) {
+		call := func() []reflect.Value {
+			return ref.Call(args)
+		}
+		return s.wait(L, funcName(ref), call, blockingResults)
+	}
+
+	ret, _ := blockingResults(L, ref.Call(args), "")
+	for _, val := range ret {
+		L.Push(val)
+	}
+	return len(ret)
+}
+
+// blockingResults converts the results of a blocking function. The first
+// value indicates whether the call succeeded. If it did not, the second value
+// is the error.
+func blockingResults(L *lua.LState, ret []reflect.Value, panicMsg string) ([]lua.LValue, error) {
+	if panicMsg != "" {
+		return []lua.LValue{lua.LFalse, lua.LString(panicMsg)}, nil
+	}
+	if n := len(ret); n > 0 && ret[n-1].Type() == refTypeError && !ret[n-1].IsNil() && GetConfig(L).RaiseErrors {
+		return []lua.LValue{lua.LFalse, errorUserData(L, ret[n-1].Interface().(error))}, nil
+	}
+
+	values := make([]lua.LValue, 0, len(ret)+1)
+	values = append(values, lua.LTrue)
+	for _, val := range ret {
+		values = append(values, New(L, val.Interface()))
+	}
+	return values, nil
+}
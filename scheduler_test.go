@@ -0,0 +1,167 @@
+package luar
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/yuin/gopher-lua"
+)
+
+func Test_scheduler_blocking(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	signals := make(chan string, 1)
+	var events []string
+
+	L.SetGlobal("await", New(L, Blocking(func() string {
+		return <-signals
+	})))
+	L.SetGlobal("signal", New(L, func(s string) {
+		signals <- s
+	}))
+	L.SetGlobal("record", New(L, func(s string) {
+		events = append(events, s)
+	}))
+
+	if err := L.DoString(`
+		function waiter()
+			record("waiter: start")
+			record("waiter: " .. await())
+		end
+		function signaler(value)
+			record("signaler: start")
+			coroutine.yield()
+			signal(value)
+			record("signaler: done")
+		end
+	`); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewScheduler(L)
+	s.Go(L.GetGlobal("waiter").(*lua.LFunction))
+	s.Go(L.GetGlobal("signaler").(*lua.LFunction), lua.LString("hello"))
+	if err := s.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"waiter: start",
+		"signaler: start",
+		"signaler: done",
+		"waiter: hello",
+	}
+	if !reflect.DeepEqual(events, expected) {
+		t.Fatalf("expecting %#v, got %#v", expected, events)
+	}
+}
+
+func Test_scheduler_channel(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	L.SetGlobal("ch", New(L, make(chan string)))
+
+	if err := L.DoString(`
+		received = {}
+		function receiver()
+			for i = 1, 2 do
+				local value, ok = ch()
+				received[i] = value
+			end
+		end
+		function sender()
+			ch("a")
+			ch("b")
+		end
+	`); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewScheduler(L)
+	s.Go(L.GetGlobal("receiver").(*lua.LFunction))
+	s.Go(L.GetGlobal("sender").(*lua.LFunction))
+	if err := s.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	testReturn(t, L, `return received[1], received[2]`, "a", "b")
+}
+
+func Test_scheduler_errors(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	GetConfig(L).RaiseErrors = true
+
+	sentinel := errors.New("sentinel")
+	L.SetGlobal("fail", New(L, Blocking(func() error {
+		return sentinel
+	})))
+	L.SetGlobal("crash", New(L, Blocking(func() {
+		panic("crashed")
+	})))
+	L.SetGlobal("ok", New(L, Blocking(func(a, b int) int {
+		return a + b
+	})))
+
+	if err := L.DoString(`
+		function failing() fail() end
+		function crashing() crash() end
+		function working() result = ok(1, 2) end
+	`); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewScheduler(L)
+	s.Go(L.GetGlobal("failing").(*lua.LFunction))
+	if err := s.Run(); UnwrapError(err) != sentinel {
+		t.Fatalf("expecting sentinel error, got %v", err)
+	}
+
+	s.Go(L.GetGlobal("crashing").(*lua.LFunction))
+	s.Go(L.GetGlobal("working").(*lua.LFunction))
+	if err := s.Run(); err == nil || !strings.Contains(err.Error(), "panic: crashed") {
+		t.Fatalf("expecting panic error, got %v", err)
+	}
+	testReturn(t, L, `return result`, "3")
+}
+
+func Test_scheduler_unscheduled(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	L.SetGlobal("add", New(L, Blocking(func(a, b int) int {
+		return a + b
+	})))
+
+	testReturn(t, L, `return add(1, 2)`, "3")
+	testReturn(t, L, `local co = coroutine.wrap(function() return add(3, 4) end); return co()`, "7")
+}
+
+func Test_scheduler_pcall(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	L.SetGlobal("add", New(L, Blocking(func(a, b int) int {
+		return a + b
+	})))
+
+	if err := L.DoString(`
+		function protected()
+			ok, result = pcall(add, 1, 2)
+		end
+	`); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewScheduler(L)
+	s.Go(L.GetGlobal("protected").(*lua.LFunction))
+	if err := s.Run(); err != nil {
+		t.Fatal(err)
+	}
+	testReturn(t, L, `return ok, result`, "true", "3")
+}
@@ -0,0 +1,261 @@
+package luar
+
+import (
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const schemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema returns a JSON Schema describing the Lua values that can be
+// converted to a Go value of type t (e.g. when a table is passed to a Go
+// function parameter of type t), using the default Config. The schema can be
+// encoded using encoding/json.
+//
+// See Config.Schema.
+func Schema(t reflect.Type) map[string]interface{} {
+	return newConfig().Schema(t)
+}
+
+// Schema returns a JSON Schema describing the Lua values that can be
+// converted to a Go value of type t by a state using c. The schema can be
+// encoded using encoding/json.
+//
+// Tables converted to structs (or pointers to structs) are described as
+// objects whose properties are the names under which the fields are accessed,
+// as chosen by c.FieldNames or c.Registry; other keys are accepted according
+// to c.UnknownKeys. Named struct types are described in "$defs", so that
+// recursive types can be referenced. Tables converted to arrays, slices, and
+// maps are described as arrays and objects, and nil as null.
+//
+// Numbers are described according to c.Conversion: with ConvertStrict,
+// integer types only accept integers within their range, and with
+// ConvertLenient, numbers and strings are interchangeable.
+//
+// Values that cannot be represented in JSON (e.g. functions and userdata) are
+// not described, except that the schema of a function type accepts any value.
+func (c *Config) Schema(t reflect.Type) map[string]interface{} {
+	s := &schemaBuilder{
+		config: c,
+		defs:   make(map[string]interface{}),
+		names:  make(map[reflect.Type]string),
+	}
+	schema := s.schema(t)
+	schema["$schema"] = schemaDraft
+	if len(s.defs) > 0 {
+		schema["$defs"] = s.defs
+	}
+	return schema
+}
+
+type schemaBuilder struct {
+	config *Config
+	defs   map[string]interface{}
+	names  map[reflect.Type]string
+}
+
+func (s *schemaBuilder) schema(t reflect.Type) map[string]interface{} {
+	if t.Implements(refTypeLuaLValue) {
+		return map[string]interface{}{}
+	}
+	if t == refTypeTable {
+		return schemaType("object", "array", "null")
+	}
+	if isBigType(t) {
+		return schemaType("number", "string", "null")
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return schemaType("boolean")
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return s.numberSchema(t)
+	case reflect.Uintptr:
+		return schemaType("number")
+	case reflect.Complex64, reflect.Complex128:
+		return map[string]interface{}{
+			"anyOf": []interface{}{
+				schemaType("number"),
+				schemaArray(schemaType("number"), 2),
+			},
+		}
+	case reflect.String:
+		if s.config.Conversion == ConvertLenient {
+			return schemaType("string", "number")
+		}
+		return schemaType("string")
+	case reflect.Interface:
+		if t.NumMethod() == 0 {
+			return map[string]interface{}{}
+		}
+		return schemaType("null")
+	case reflect.Func:
+		return map[string]interface{}{}
+	case reflect.Array:
+		return schemaArray(s.schema(t.Elem()), t.Len())
+	case reflect.Slice:
+		schema := schemaNullable(schemaArray(s.schema(t.Elem()), -1))
+		if k := t.Elem().Kind(); k == reflect.Uint8 || k == reflect.Int32 {
+			// strings can be converted to []byte and []rune
+			return map[string]interface{}{
+				"anyOf": []interface{}{schemaType("string"), schema},
+			}
+		}
+		return schema
+	case reflect.Map:
+		return schemaNullable(s.mapSchema(t))
+	case reflect.Ptr:
+		if t.Elem().Kind() == reflect.Struct {
+			return schemaNullable(s.structSchema(t.Elem()))
+		}
+	case reflect.Struct:
+		return s.structSchema(t)
+	}
+	// channels, unsafe pointers, and other pointers only accept nil (or
+	// userdata)
+	return schemaType("null")
+}
+
+// numberSchema returns the schema of the numeric type t, according to the
+// conversion policy.
+func (s *schemaBuilder) numberSchema(t reflect.Type) map[string]interface{} {
+	switch s.config.Conversion {
+	case ConvertLenient:
+		return schemaType("number", "string")
+	case ConvertStrict:
+		switch t.Kind() {
+		case reflect.Float32, reflect.Float64:
+			return schemaType("number")
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return map[string]interface{}{
+				"type":    "integer",
+				"minimum": -(int64(1) << uint(t.Bits()-1)),
+				"maximum": int64(math.MaxInt64 >> uint(64-t.Bits())),
+			}
+		}
+		return map[string]interface{}{
+			"type":    "integer",
+			"minimum": 0,
+			"maximum": uint64(math.MaxUint64 >> uint(64-t.Bits())),
+		}
+	}
+	return schemaType("number")
+}
+
+func (s *schemaBuilder) mapSchema(t reflect.Type) map[string]interface{} {
+	elem := s.schema(t.Elem())
+	object := map[string]interface{}{
+		"type":                 "object",
+		"additionalProperties": elem,
+	}
+
+	switch t.Key().Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		// numeric keys are written as strings in JSON objects, and a Lua
+		// sequence is also a table with numeric keys
+		object["propertyNames"] = map[string]interface{}{
+			"pattern": `^-?[0-9]+(\.[0-9]+)?([eE][-+]?[0-9]+)?$`,
+		}
+		return map[string]interface{}{
+			"anyOf": []interface{}{object, schemaArray(elem, -1)},
+		}
+	}
+	return object
+}
+
+// structSchema returns a reference to the definition of the named struct type
+// t, or the schema of the unnamed struct type t.
+func (s *schemaBuilder) structSchema(t reflect.Type) map[string]interface{} {
+	if t.Name() == "" {
+		return s.fieldsSchema(t)
+	}
+
+	name, ok := s.names[t]
+	if !ok {
+		name = t.String()
+		for i := 2; s.defs[name] != nil; i++ {
+			name = t.String() + "_" + strconv.Itoa(i)
+		}
+		s.names[t] = name
+		// reserve the name before describing the fields, which may refer
+		// to t
+		s.defs[name] = true
+		s.defs[name] = s.fieldsSchema(t)
+	}
+	return map[string]interface{}{
+		"$ref": "#/$defs/" + schemaPointerEscape(name),
+	}
+}
+
+func (s *schemaBuilder) fieldsSchema(t reflect.Type) map[string]interface{} {
+	var fields []fieldInfo
+	if s.config.Registry != nil {
+		fields = s.config.Registry.lookup(t).fields
+	} else {
+		fields = namedFields(t, s.config.FieldNames)
+	}
+
+	properties := make(map[string]interface{})
+	for _, field := range fields {
+		schema := s.schema(field.accessor.typ)
+		for _, name := range field.names {
+			properties[name] = schema
+		}
+	}
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	switch s.config.UnknownKeys {
+	case UnknownKeysIgnore:
+	case UnknownKeysCollect:
+		if index := extraField(s.config, t); index != nil {
+			schema["additionalProperties"] = s.schema(t.FieldByIndex(index).Type.Elem())
+		}
+	default:
+		schema["additionalProperties"] = false
+	}
+	return schema
+}
+
+func schemaType(types ...string) map[string]interface{} {
+	if len(types) == 1 {
+		return map[string]interface{}{"type": types[0]}
+	}
+	return map[string]interface{}{"type": types}
+}
+
+// schemaArray returns the schema of an array of items, of the given length if
+// it is not negative.
+func schemaArray(items map[string]interface{}, length int) map[string]interface{} {
+	schema := map[string]interface{}{
+		"type":  "array",
+		"items": items,
+	}
+	if length >= 0 {
+		schema["minItems"] = length
+		schema["maxItems"] = length
+	}
+	return schema
+}
+
+// schemaNullable returns schema, also accepting null.
+func schemaNullable(schema map[string]interface{}) map[string]interface{} {
+	if typ, ok := schema["type"].(string); ok {
+		schema["type"] = []string{typ, "null"}
+		return schema
+	}
+	return map[string]interface{}{
+		"anyOf": []interface{}{schema, schemaType("null")},
+	}
+}
+
+func schemaPointerEscape(name string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(name)
+}
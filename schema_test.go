@@ -0,0 +1,166 @@
+package luar
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/yuin/gopher-lua"
+)
+
+type schemaTestConfig struct {
+	Name     string
+	Port     int      `luar:"port"`
+	Secret   string   `luar:"-"`
+	Hosts    []string `luar:"hosts"`
+	Matrix   [2][2]float64
+	Limits   map[string]int
+	Codes    map[int]string
+	Data     []byte
+	Extra    interface{}
+	Parent   *schemaTestConfig
+	Handler  func(string) error
+	Anon     struct{ X bool }
+	Value    lua.LValue
+	internal int
+}
+
+func schemaJSON(t *testing.T, schema map[string]interface{}) string {
+	b, err := json.Marshal(schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func Test_schema(t *testing.T) {
+	schema := Schema(reflect.TypeOf(schemaTestConfig{}))
+
+	if schema["$ref"] != "#/$defs/luar.schemaTestConfig" || schema["$schema"] != schemaDraft {
+		t.Fatalf("unexpected root schema %v", schemaJSON(t, schema))
+	}
+	def := schema["$defs"].(map[string]interface{})["luar.schemaTestConfig"].(map[string]interface{})
+	if def["additionalProperties"] != false {
+		t.Fatal("expecting additional properties to be rejected")
+	}
+	properties := def["properties"].(map[string]interface{})
+
+	for name, expected := range map[string]string{
+		"Name":   `{"type":"string"}`,
+		"name":   `{"type":"string"}`,
+		"port":   `{"type":"number"}`,
+		"hosts":  `{"items":{"type":"string"},"type":["array","null"]}`,
+		"Matrix": `{"items":{"items":{"type":"number"},"maxItems":2,"minItems":2,"type":"array"},"maxItems":2,"minItems":2,"type":"array"}`,
+		"Limits": `{"additionalProperties":{"type":"number"},"type":["object","null"]}`,
+		"Codes":  `{"anyOf":[{"anyOf":[{"additionalProperties":{"type":"string"},"propertyNames":{"pattern":"^-?[0-9]+(\\.[0-9]+)?([eE][-+]?[0-9]+)?$"},"type":"object"},{"items":{"type":"string"},"type":"array"}]},{"type":"null"}]}`,
+		"Data":   `{"anyOf":[{"type":"string"},{"items":{"type":"number"},"type":["array","null"]}]}`,
+		"Extra":  `{}`,
+		"Parent": `{"anyOf":[{"$ref":"#/$defs/luar.schemaTestConfig"},{"type":"null"}]}`,
+		"anon":   `{"additionalProperties":false,"properties":{"X":{"type":"boolean"},"x":{"type":"boolean"}},"type":"object"}`,
+		"Value":  `{}`,
+	} {
+		property, ok := properties[name].(map[string]interface{})
+		if !ok {
+			t.Fatalf("missing property %s", name)
+		}
+		if got := schemaJSON(t, property); got != expected {
+			t.Fatalf("%s: expecting %s, got %s", name, expected, got)
+		}
+	}
+	for _, name := range []string{"Port", "Secret", "secret", "internal"} {
+		if _, ok := properties[name]; ok {
+			t.Fatalf("unexpected property %s", name)
+		}
+	}
+}
+
+func Test_schema_basic(t *testing.T) {
+	for _, test := range []struct {
+		value    interface{}
+		expected string
+	}{
+		{true, `{"$schema":"` + schemaDraft + `","type":"boolean"}`},
+		{[]int{}, `{"$schema":"` + schemaDraft + `","items":{"type":"number"},"type":["array","null"]}`},
+		{complex64(0), `{"$schema":"` + schemaDraft + `","anyOf":[{"type":"number"},{"items":{"type":"number"},"maxItems":2,"minItems":2,"type":"array"}]}`},
+		{(*int)(nil), `{"$schema":"` + schemaDraft + `","type":"null"}`},
+	} {
+		if got := schemaJSON(t, Schema(reflect.TypeOf(test.value))); got != test.expected {
+			t.Fatalf("%T: expecting %s, got %s", test.value, test.expected, got)
+		}
+	}
+}
+
+func Test_schema_config(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	config := GetConfig(L)
+	config.FieldNames = func(s reflect.Type, f reflect.StructField) []string {
+		if f.Name == "Port" {
+			return []string{"listen_port"}
+		}
+		return nil
+	}
+
+	schema := config.Schema(reflect.TypeOf(&schemaTestConfig{}))
+	expected := `{"additionalProperties":false,"properties":{"listen_port":{"type":"number"}},"type":"object"}`
+	if got := schemaJSON(t, schema["$defs"].(map[string]interface{})["luar.schemaTestConfig"].(map[string]interface{})); got != expected {
+		t.Fatalf("expecting %s, got %s", expected, got)
+	}
+	if got := schemaJSON(t, schema["anyOf"].([]interface{})[1].(map[string]interface{})); got != `{"type":"null"}` {
+		t.Fatalf("expecting pointer to accept null, got %s", got)
+	}
+
+	// the schema matches the conversion performed by the state
+	var got *schemaTestConfig
+	L.SetGlobal("set", New(L, func(c *schemaTestConfig) { got = c }))
+	testReturn(t, L, `set({listen_port = 80})`)
+	if got == nil || got.Port != 80 {
+		t.Fatalf("unexpected value %v", got)
+	}
+	testError(t, L, `set({port = 80})`, "port")
+}
+
+func Test_schema_unknown(t *testing.T) {
+	config := newConfig()
+	for policy, expected := range map[UnknownKeyPolicy]string{
+		UnknownKeysDefault: `false`,
+		UnknownKeysIgnore:  `null`,
+		UnknownKeysError:   `false`,
+		UnknownKeysCollect: `{}`,
+	} {
+		config.UnknownKeys = policy
+		schema := config.Schema(reflect.TypeOf(unknownTestOptions{}))
+		def := schema["$defs"].(map[string]interface{})["luar.unknownTestOptions"].(map[string]interface{})
+		b, err := json.Marshal(def["additionalProperties"])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != expected {
+			t.Fatalf("%d: expecting %s, got %s", policy, expected, b)
+		}
+		if properties := def["properties"].(map[string]interface{}); len(properties) != 2 {
+			t.Fatalf("%d: expecting only the name properties, got %v", policy, properties)
+		}
+	}
+}
+
+func Test_schema_conversion(t *testing.T) {
+	config := newConfig()
+	for _, test := range []struct {
+		policy   ConversionPolicy
+		value    interface{}
+		expected string
+	}{
+		{ConvertStrict, int8(0), `{"$schema":"` + schemaDraft + `","maximum":127,"minimum":-128,"type":"integer"}`},
+		{ConvertStrict, uint16(0), `{"$schema":"` + schemaDraft + `","maximum":65535,"minimum":0,"type":"integer"}`},
+		{ConvertStrict, float32(0), `{"$schema":"` + schemaDraft + `","type":"number"}`},
+		{ConvertLenient, 0, `{"$schema":"` + schemaDraft + `","type":["number","string"]}`},
+		{ConvertLenient, "", `{"$schema":"` + schemaDraft + `","type":["string","number"]}`},
+	} {
+		config.Conversion = test.policy
+		if got := schemaJSON(t, config.Schema(reflect.TypeOf(test.value))); got != test.expected {
+			t.Fatalf("%T: expecting %s, got %s", test.value, test.expected, got)
+		}
+	}
+}
@@ -71,7 +71,7 @@ func sliceCall(L *lua.LState) int {
 		return 2
 	}
 
-	L.Push(L.NewFunction(fn))
+	L.Push(newFunction(L, fn))
 	return 1
 }
 
@@ -2,6 +2,7 @@ package luar
 
 import (
 	"reflect"
+	"unsafe"
 
 	"github.com/yuin/gopher-lua"
 )
@@ -10,58 +11,30 @@ func structIndex(L *lua.LState) int {
 	ref, mt := check(L, 1)
 	key := L.CheckString(2)
 
-	if fn := mt.method(key); fn != nil {
-		L.Push(fn)
+	switch member := mt.member(key).(type) {
+	case *lua.LFunction:
+		L.Push(member)
+		return 1
+	case *lua.LUserData:
+		member.Value.(*fieldAccessor).get(L, reflect.Indirect(ref), key)
 		return 1
 	}
-
-	ref = reflect.Indirect(ref)
-	index := mt.fieldIndex(key)
-	if index == nil {
-		return 0
-	}
-	field := ref.FieldByIndex(index)
-	if !field.CanInterface() {
-		L.RaiseError("cannot interface field " + key)
-	}
-
-	if (field.Kind() == reflect.Struct || field.Kind() == reflect.Array) && field.CanAddr() {
-		field = field.Addr()
-	}
-	L.Push(New(L, field.Interface()))
-	return 1
+	return 0
 }
 
 func structPtrIndex(L *lua.LState) int {
 	ref, mt := check(L, 1)
 	key := L.CheckString(2)
 
-	if fn := mt.method(key); fn != nil {
-		L.Push(fn)
+	switch member := mt.member(key).(type) {
+	case *lua.LFunction:
+		L.Push(member)
 		return 1
-	}
-
-	ref = ref.Elem()
-	mt = MT(L, ref.Interface())
-	if fn := mt.method(key); fn != nil {
-		L.Push(fn)
+	case *lua.LUserData:
+		member.Value.(*fieldAccessor).getPtr(L, ref, key)
 		return 1
 	}
-
-	index := mt.fieldIndex(key)
-	if index == nil {
-		return 0
-	}
-	field := ref.FieldByIndex(index)
-	if !field.CanInterface() {
-		L.RaiseError("cannot interface field " + key)
-	}
-
-	if (field.Kind() == reflect.Struct || field.Kind() == reflect.Array) && field.CanAddr() {
-		field = field.Addr()
-	}
-	L.Push(New(L, field.Interface()))
-	return 1
+	return 0
 }
 
 func structPtrNewIndex(L *lua.LState) int {
@@ -69,17 +42,23 @@ func structPtrNewIndex(L *lua.LState) int {
 	key := L.CheckString(2)
 	value := L.CheckAny(3)
 
-	ref = ref.Elem()
-	mt = MT(L, ref.Interface())
-
-	index := mt.fieldIndex(key)
-	if index == nil {
+	accessor := mt.field(key)
+	if accessor == nil {
 		L.RaiseError("unknown field " + key)
 	}
-	field := ref.FieldByIndex(index)
+	if accessor.setPtr(ref, value) {
+		return 0
+	}
+	field := accessor.field(ref.Elem())
 	if !field.CanSet() {
 		L.RaiseError("cannot set field " + key)
 	}
+	if tbl, ok := value.(*lua.LTable); ok && GetConfig(L).MergeAssign {
+		if err := mergeValue(L, tbl, field); err != nil {
+			L.ArgError(2, err.Error())
+		}
+		return 0
+	}
 	val, err := lValueToReflect(L, value, field.Type(), nil)
 	if err != nil {
 		L.ArgError(2, err.Error())
@@ -95,3 +74,152 @@ func structEq(L *lua.LState) int {
 	L.Push(lua.LBool(ref1.Interface() == ref2.Interface()))
 	return 1
 }
+
+// fieldAccessor reads and writes a struct field, the value of the userdata in
+// a metatable's fields table. It is computed once per field.
+type fieldAccessor struct {
+	index []int
+	typ   reflect.Type
+
+	// if direct, the field is stored at offset from the start of the struct
+	// (i.e. it is not promoted through an embedded pointer).
+	direct bool
+	offset uintptr
+	// if fast, the field is a predeclared bool, numeric, or string type that
+	// can be accessed directly in memory.
+	fast bool
+}
+
+func newFieldAccessor(vtype reflect.Type, index []int) *fieldAccessor {
+	a := &fieldAccessor{
+		index:  index,
+		direct: true,
+	}
+	t := vtype
+	for _, fieldIndex := range index {
+		if t.Kind() == reflect.Ptr {
+			a.direct = false
+			t = t.Elem()
+		}
+		field := t.Field(fieldIndex)
+		a.offset += field.Offset
+		t = field.Type
+	}
+	a.typ = t
+
+	switch t.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		a.fast = a.direct && t.PkgPath() == ""
+	}
+	return a
+}
+
+// field returns the field of the struct ref.
+func (a *fieldAccessor) field(ref reflect.Value) reflect.Value {
+	if len(a.index) == 1 {
+		return ref.Field(a.index[0])
+	}
+	return ref.FieldByIndex(a.index)
+}
+
+// get pushes the value of the field, named key, of the struct ref.
+func (a *fieldAccessor) get(L *lua.LState, ref reflect.Value, key string) {
+	field := a.field(ref)
+	if !field.CanInterface() {
+		L.RaiseError("cannot interface field " + key)
+	}
+	if (field.Kind() == reflect.Struct || field.Kind() == reflect.Array) && field.CanAddr() {
+		field = field.Addr()
+	}
+	L.Push(New(L, field.Interface()))
+}
+
+// pointer returns a pointer to the field of the struct pointed to by ptr.
+func (a *fieldAccessor) pointer(ptr reflect.Value) unsafe.Pointer {
+	base := unsafe.Pointer(ptr.Pointer())
+	return unsafe.Pointer(uintptr(base) + a.offset)
+}
+
+// getPtr pushes the value of the field, named key, of the struct pointed to
+// by ptr.
+func (a *fieldAccessor) getPtr(L *lua.LState, ptr reflect.Value, key string) {
+	if !a.fast || ptr.IsNil() {
+		a.get(L, ptr.Elem(), key)
+		return
+	}
+
+	p := a.pointer(ptr)
+	switch a.typ.Kind() {
+	case reflect.Bool:
+		L.Push(lua.LBool(*(*bool)(p)))
+	case reflect.String:
+		L.Push(lua.LString(*(*string)(p)))
+	case reflect.Int:
+		L.Push(lua.LNumber(*(*int)(p)))
+	case reflect.Int8:
+		L.Push(lua.LNumber(*(*int8)(p)))
+	case reflect.Int16:
+		L.Push(lua.LNumber(*(*int16)(p)))
+	case reflect.Int32:
+		L.Push(lua.LNumber(*(*int32)(p)))
+	case reflect.Int64:
+		L.Push(lua.LNumber(*(*int64)(p)))
+	case reflect.Uint:
+		L.Push(lua.LNumber(*(*uint)(p)))
+	case reflect.Uint8:
+		L.Push(lua.LNumber(*(*uint8)(p)))
+	case reflect.Uint16:
+		L.Push(lua.LNumber(*(*uint16)(p)))
+	case reflect.Uint32:
+		L.Push(lua.LNumber(*(*uint32)(p)))
+	case reflect.Uint64:
+		L.Push(lua.LNumber(*(*uint64)(p)))
+	case reflect.Float32:
+		L.Push(lua.LNumber(*(*float32)(p)))
+	case reflect.Float64:
+		L.Push(lua.LNumber(*(*float64)(p)))
+	}
+}
+
+// setPtr sets the field of the struct pointed to by ptr to value, if value
+// can be stored without conversion. false is returned otherwise.
+func (a *fieldAccessor) setPtr(ptr reflect.Value, value lua.LValue) bool {
+	if !a.fast || ptr.IsNil() {
+		return false
+	}
+
+	p := a.pointer(ptr)
+	switch converted := value.(type) {
+	case lua.LBool:
+		if a.typ.Kind() != reflect.Bool {
+			return false
+		}
+		*(*bool)(p) = bool(converted)
+	case lua.LString:
+		if a.typ.Kind() != reflect.String {
+			return false
+		}
+		*(*string)(p) = string(converted)
+	case lua.LNumber:
+		// only conversions that lose nothing, so that Config.Conversion
+		// need not be checked
+		if !isNumericKind(a.typ.Kind()) || numberLoss(converted, a.typ) != "" {
+			return false
+		}
+		elem := reflect.NewAt(a.typ, p).Elem()
+		switch a.typ.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			elem.SetInt(int64(converted))
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			elem.SetUint(uint64(converted))
+		default:
+			elem.SetFloat(float64(converted))
+		}
+	default:
+		return false
+	}
+	return true
+}
@@ -449,3 +449,70 @@ func Test_struct_convert_mismatch_type(t *testing.T) {
 		}
 	}
 }
+
+type test_struct_accessor_inner struct {
+	Int8    int8
+	Uint16  uint16
+	Float32 float32
+}
+
+type test_struct_accessor_named int
+
+type test_struct_accessor struct {
+	Bool  bool
+	Str   string
+	Int   int
+	Int64 int64
+	Uint  uint
+	Named test_struct_accessor_named
+	test_struct_accessor_inner
+	*Test_nested_child1
+}
+
+func Test_struct_accessor(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	s := &test_struct_accessor{
+		Test_nested_child1: &Test_nested_child1{},
+	}
+	L.SetGlobal("s", New(L, s))
+
+	testReturn(t, L, `
+		s.Bool = true
+		s.Str = "abc"
+		s.Int = -3.7
+		s.Int64 = 1e15
+		s.Uint = 7
+		s.Named = 4
+		s.Int8 = 127
+		s.Uint16 = 65535
+		s.Float32 = 0.5
+		s.Name = "child"
+		return s.Bool, s.Str, s.Int, s.Int64, s.Uint, s.Named, s.Int8, s.Uint16, s.Float32, s.Name
+	`, "true", "abc", "-3", "1000000000000000", "7", "4", "127", "65535", "0.5", "child")
+
+	expected := test_struct_accessor{
+		Bool:  true,
+		Str:   "abc",
+		Int:   -3,
+		Int64: 1e15,
+		Uint:  7,
+		Named: 4,
+		test_struct_accessor_inner: test_struct_accessor_inner{
+			Int8:    127,
+			Uint16:  65535,
+			Float32: 0.5,
+		},
+		Test_nested_child1: s.Test_nested_child1,
+	}
+	if *s != expected || s.Name != "child" {
+		t.Fatalf("expecting %+v, got %+v", expected, *s)
+	}
+
+	testError(t, L, `s.Int = "x"`, "bad argument")
+	testError(t, L, `s.Str = {}`, "bad argument")
+
+	s.Test_nested_child1 = nil
+	testError(t, L, `return s.Name`, "nil pointer")
+}
@@ -0,0 +1,483 @@
+package luar
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/yuin/gopher-lua"
+)
+
+// WriteStubs writes to w a definition file for the Lua language server
+// (LuaLS, which uses EmmyLua annotations) describing globals, as if each
+// value were set with L.SetGlobal(name, New(L, value)). A value that is a
+// reflect.Type is described as the type generator returned by NewType.
+//
+// A ---@class is written for each Go type that New converts to userdata with
+// a metatable, including the types of fields, method parameters and results,
+// slice elements, and so on. Each class is read from the metatable L uses for
+// the type, so it lists the fields and methods under the names they are
+// accessed with (i.e. as chosen by Config.FieldNames, Config.MethodNames, or
+// Config.Registry), along with the operators the metatable defines.
+//
+// Classes are named after their Go type (e.g. "http.Header"); pointer types
+// have the "Ptr" suffix (e.g. "http.RequestPtr"). Unnamed slice and map
+// types are described as Lua arrays and tables. Go parameters are named p1,
+// p2, and so on.
+func WriteStubs(w io.Writer, L *lua.LState, globals map[string]interface{}) error {
+	s := &stubWriter{
+		L:       L,
+		classes: make(map[reflect.Type]string),
+	}
+
+	names := make([]string, 0, len(globals))
+	for name := range globals {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString("\n")
+		s.writeGlobal(&b, name, globals[name])
+	}
+	// writing a class can add more classes to the queue
+	for i := 0; i < len(s.queue); i++ {
+		b.WriteString("\n")
+		s.writeClass(&b, s.queue[i])
+	}
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString("---@meta\n")
+	bw.WriteString(b.String())
+	return bw.Flush()
+}
+
+type stubWriter struct {
+	L       *lua.LState
+	classes map[reflect.Type]string
+	queue   []reflect.Type
+}
+
+var stubOperators = []struct {
+	event, name string
+}{
+	{"__add", "add"},
+	{"__sub", "sub"},
+	{"__mul", "mul"},
+	{"__div", "div"},
+	{"__mod", "mod"},
+	{"__pow", "pow"},
+	{"__concat", "concat"},
+	{"__unm", "unm"},
+	{"__len", "len"},
+	{"__call", "call"},
+}
+
+func (s *stubWriter) writeGlobal(b *strings.Builder, name string, value interface{}) {
+	target := name
+	if !isLuaIdentifier(name) {
+		target = "_G[" + strconv.Quote(name) + "]"
+	}
+
+	switch v := value.(type) {
+	case reflect.Type:
+		fmt.Fprintf(b, "---@type %s\n%s = nil\n", s.typeGenerator(v), target)
+		return
+	case lua.LValue:
+		fmt.Fprintf(b, "---@type %s\n%s = nil\n", stubLuaType(v), target)
+		return
+	case nil:
+		fmt.Fprintf(b, "---@type nil\n%s = nil\n", target)
+		return
+	}
+
+	t := reflect.TypeOf(value)
+	if t.Kind() == reflect.Func && !isPreservedType(s.L, t) && isLuaIdentifier(name) {
+		s.writeFunction(b, "function "+name, t, 0)
+		return
+	}
+	fmt.Fprintf(b, "---@type %s\n%s = nil\n", s.luaType(t), target)
+}
+
+// typeGenerator returns the Lua type of the value returned by NewType for t.
+func (s *stubWriter) typeGenerator(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Chan:
+		return "fun(buffer?: integer): " + s.luaType(t)
+	case reflect.Map:
+		return "fun(): " + s.luaType(t)
+	case reflect.Slice:
+		return "fun(length?: integer, capacity?: integer): " + s.luaType(t)
+	}
+	ptr := reflect.PtrTo(t)
+	if isBigType(ptr) {
+		return "fun(value?: number|string): " + s.luaType(ptr)
+	}
+	return "fun(): " + s.luaType(ptr)
+}
+
+// luaType returns the Lua type that values of t are converted to by New.
+func (s *stubWriter) luaType(t reflect.Type) string {
+	if isPreservedType(s.L, t) {
+		return s.class(t)
+	}
+	if t == refTypeTable {
+		return "table"
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.String:
+		return "string"
+	case reflect.Interface:
+		return "any"
+	case reflect.Func:
+		return s.funcType(t)
+	case reflect.Slice, reflect.Array, reflect.Map:
+		if t.Name() == "" {
+			return s.tableType(t)
+		}
+		return s.class(t)
+	case reflect.Chan, reflect.Complex64, reflect.Complex128, reflect.Struct, reflect.Ptr:
+		return s.class(t)
+	}
+	return "userdata"
+}
+
+// tableType returns the Lua table type that best describes the unnamed
+// slice, array, or map type t.
+func (s *stubWriter) tableType(t reflect.Type) string {
+	if t.Kind() == reflect.Map {
+		return "table<" + s.luaType(t.Key()) + ", " + s.luaType(t.Elem()) + ">"
+	}
+	elem := s.elemType(t.Elem(), t.Kind() == reflect.Slice)
+	if strings.HasPrefix(elem, "fun(") || strings.Contains(elem, "|") {
+		elem = "(" + elem + ")"
+	}
+	return elem + "[]"
+}
+
+// elemType returns the Lua type of an element of type t, where addressable
+// is true if the element is addressable, in which case struct and array
+// values are converted as pointers.
+func (s *stubWriter) elemType(t reflect.Type, addressable bool) string {
+	if addressable && (t.Kind() == reflect.Struct || t.Kind() == reflect.Array) && !isPreservedType(s.L, t) {
+		return s.luaType(reflect.PtrTo(t))
+	}
+	return s.luaType(t)
+}
+
+// funcType returns the Lua type of the function type t.
+func (s *stubWriter) funcType(t reflect.Type) string {
+	if funcIsBypass(t) {
+		return "fun(...): ..."
+	}
+	params, results := s.signature(t, 0)
+
+	var b strings.Builder
+	b.WriteString("fun(")
+	for i, param := range params {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(param[0] + ": " + param[1])
+	}
+	b.WriteString(")")
+	if len(results) > 0 {
+		b.WriteString(": " + strings.Join(results, ", "))
+	}
+	return b.String()
+}
+
+// signature returns the names and Lua types of the parameters, and the Lua
+// types of the results, of the function type t, skipping its first skip
+// parameters (e.g. a method receiver).
+func (s *stubWriter) signature(t reflect.Type, skip int) (params [][2]string, results []string) {
+	for i := skip; i < t.NumIn(); i++ {
+		name := "p" + strconv.Itoa(i-skip+1)
+		typ := t.In(i)
+		if t.IsVariadic() && i == t.NumIn()-1 {
+			name = "..."
+			typ = typ.Elem()
+		}
+		params = append(params, [2]string{name, s.paramType(typ)})
+	}
+	for i := 0; i < t.NumOut(); i++ {
+		results = append(results, s.luaType(t.Out(i)))
+	}
+	return params, results
+}
+
+// paramType returns the Lua type of the values accepted for a parameter of
+// type t.
+func (s *stubWriter) paramType(t reflect.Type) string {
+	typ := s.luaType(t)
+	if isPreservedType(s.L, t) {
+		return typ
+	}
+	if isBigType(t) {
+		return typ + "|number|string"
+	}
+	if t == refTypeTable {
+		return typ
+	}
+	switch t.Kind() {
+	case reflect.Struct, reflect.Ptr:
+		if t.Kind() == reflect.Ptr && t.Elem().Kind() != reflect.Struct {
+			return typ
+		}
+		return typ + "|table"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		if t.Name() != "" {
+			return typ + "|table"
+		}
+	}
+	return typ
+}
+
+// writeFunction writes the annotations and declaration, decl, of a function
+// of type t, skipping its first skip parameters. A method (i.e. skip > 0)
+// that is not declared using the "function T:name" syntax is given an
+// explicit self parameter.
+func (s *stubWriter) writeFunction(b *strings.Builder, decl string, t reflect.Type, skip int) {
+	if funcIsBypass(t) {
+		fmt.Fprintf(b, "---@param ... any\n---@return any ...\n%s(...) end\n", decl)
+		return
+	}
+
+	params, results := s.signature(t, skip)
+	var names []string
+	if skip > 0 && !strings.HasPrefix(decl, "function ") {
+		names = append(names, "self")
+	}
+	for _, param := range params {
+		fmt.Fprintf(b, "---@param %s %s\n", param[0], param[1])
+		names = append(names, param[0])
+	}
+	for _, result := range results {
+		fmt.Fprintf(b, "---@return %s\n", result)
+	}
+	fmt.Fprintf(b, "%s(%s) end\n", decl, strings.Join(names, ", "))
+}
+
+// class returns the class name of t, queueing the class to be written.
+func (s *stubWriter) class(t reflect.Type) string {
+	if name, ok := s.classes[t]; ok {
+		return name
+	}
+	name := stubClassName(t)
+	s.classes[t] = name
+	s.queue = append(s.queue, t)
+	return name
+}
+
+func stubClassName(t reflect.Type) string {
+	if t.Kind() == reflect.Ptr && t.Name() == "" {
+		return stubClassName(t.Elem()) + "Ptr"
+	}
+	return strings.Map(func(r rune) rune {
+		if r == '.' || r == '_' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' {
+			return r
+		}
+		return '_'
+	}, t.String())
+}
+
+func (s *stubWriter) writeClass(b *strings.Builder, t reflect.Type) {
+	name := s.classes[t]
+	mt := getMetatable(s.L, t)
+
+	fmt.Fprintf(b, "---@class %s\n", name)
+
+	if fields, ok := mt.RawGetString("fields").(*lua.LTable); ok {
+		addressable := t.Kind() == reflect.Ptr
+		for _, field := range sortedKeys(fields) {
+			accessor := fields.RawGetString(field).(*lua.LUserData).Value.(*fieldAccessor)
+			fmt.Fprintf(b, "---@field %s %s\n", stubFieldName(field), s.elemType(accessor.typ, addressable))
+		}
+	}
+
+	container := t
+	if t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Array {
+		container = t.Elem()
+	}
+	switch container.Kind() {
+	case reflect.Slice, reflect.Array:
+		addressable := container.Kind() == reflect.Slice || t.Kind() == reflect.Ptr
+		fmt.Fprintf(b, "---@field [integer] %s\n", s.elemType(container.Elem(), addressable))
+	case reflect.Map:
+		fmt.Fprintf(b, "---@field [%s] %s\n", s.luaType(container.Key()), s.luaType(container.Elem()))
+	}
+
+	for _, op := range stubOperators {
+		if fn := mt.RawGetString(op.event); fn != lua.LNil {
+			fmt.Fprintf(b, "---@operator %s\n", s.operator(t, name, op.name))
+		}
+	}
+
+	methods := mt.RawGetString("methods").(*lua.LTable)
+	keys := sortedKeys(methods)
+	if len(keys) == 0 {
+		return
+	}
+
+	local := strings.Replace(name, ".", "_", -1)
+	fmt.Fprintf(b, "local %s = {}\n", local)
+	for _, method := range keys {
+		fn := methods.RawGetString(method).(*lua.LFunction)
+		decl := "function " + local + ":" + method
+		if !isLuaIdentifier(method) {
+			// methods are called with the receiver as their first argument
+			decl = local + "[" + strconv.Quote(method) + "] = function"
+		}
+
+		b.WriteString("\n")
+		if len(fn.Upvalues) > 0 {
+			if ud, ok := fn.Upvalues[0].Value().(*lua.LUserData); ok {
+				if ref, ok := ud.Value.(reflect.Value); ok {
+					s.writeFunction(b, decl, ref.Type(), 1)
+					continue
+				}
+			}
+		}
+		s.writeBuiltinMethod(b, decl, name, method)
+	}
+}
+
+// writeBuiltinMethod writes the annotations of a method that luar adds to the
+// metatable of a type (e.g. the real method of complex numbers).
+func (s *stubWriter) writeBuiltinMethod(b *strings.Builder, decl, class, method string) {
+	switch method {
+	case "real", "imag", "abs", "phase":
+		fmt.Fprintf(b, "---@return number\n%s() end\n", decl)
+	case "conj":
+		fmt.Fprintf(b, "---@return %s\n%s() end\n", class, decl)
+	case "Unwrap":
+		fmt.Fprintf(b, "---@return any\n%s() end\n", decl)
+	case "is":
+		fmt.Fprintf(b, "---@param p1 any\n---@return boolean\n%s(p1) end\n", decl)
+	case "await":
+		fmt.Fprintf(b, "---@return any\n---@return any\n%s() end\n", decl)
+	default:
+		fmt.Fprintf(b, "---@param ... any\n---@return any ...\n%s(...) end\n", decl)
+	}
+}
+
+// operator returns the annotation of the operator op of the class name,
+// which describes t.
+func (s *stubWriter) operator(t reflect.Type, name, op string) string {
+	switch op {
+	case "len":
+		return "len: integer"
+	case "unm":
+		switch t.Kind() {
+		case reflect.Chan:
+			return "unm: nil"
+		case reflect.Ptr:
+			if !isBigType(t) {
+				return "unm: " + s.luaType(t.Elem())
+			}
+		}
+		return "unm: " + name
+	case "concat":
+		return "concat(string): " + name
+	case "call":
+		switch t.Kind() {
+		case reflect.Chan:
+			if t.ChanDir()&reflect.RecvDir != 0 {
+				return "call: " + s.luaType(t.Elem()) + ", boolean"
+			}
+			return "call(" + s.paramType(t.Elem()) + ")"
+		case reflect.Map:
+			return "call: fun(): " + s.luaType(t.Key()) + ", " + s.luaType(t.Elem())
+		case reflect.Ptr:
+			t = t.Elem()
+		}
+		return "call: fun(): integer, " + s.elemType(t.Elem(), t.Kind() == reflect.Slice)
+	}
+
+	switch {
+	case t.Kind() == reflect.Slice:
+		// append
+		return op + "(" + s.paramType(t.Elem()) + "): " + name
+	case t.Kind() == reflect.Ptr && !isBigType(t):
+		// set the pointed to value
+		return op + "(" + s.paramType(t.Elem()) + "): " + name
+	case t.Kind() == reflect.String:
+		return op + "(string): " + name
+	case t.Kind() == reflect.Complex64 || t.Kind() == reflect.Complex128:
+		return op + "(" + name + "|number): " + name
+	case isBigType(t):
+		return op + "(" + name + "|number|string): " + name
+	}
+	return op + "(" + name + "|number): " + name
+}
+
+func sortedKeys(tbl *lua.LTable) []string {
+	var keys []string
+	tbl.ForEach(func(key, _ lua.LValue) {
+		if str, ok := key.(lua.LString); ok {
+			keys = append(keys, string(str))
+		}
+	})
+	sort.Strings(keys)
+	return keys
+}
+
+func stubFieldName(name string) string {
+	if isLuaIdentifier(name) {
+		return name
+	}
+	return "[" + strconv.Quote(name) + "]"
+}
+
+func stubLuaType(v lua.LValue) string {
+	switch v.Type() {
+	case lua.LTNil:
+		return "nil"
+	case lua.LTBool:
+		return "boolean"
+	case lua.LTNumber:
+		return "number"
+	case lua.LTString:
+		return "string"
+	case lua.LTFunction:
+		return "function"
+	case lua.LTTable:
+		return "table"
+	case lua.LTThread:
+		return "thread"
+	}
+	return "any"
+}
+
+var luaKeywords = map[string]bool{
+	"and": true, "break": true, "do": true, "else": true, "elseif": true,
+	"end": true, "false": true, "for": true, "function": true, "goto": true,
+	"if": true, "in": true, "local": true, "nil": true, "not": true,
+	"or": true, "repeat": true, "return": true, "then": true, "true": true,
+	"until": true, "while": true,
+}
+
+func isLuaIdentifier(name string) bool {
+	if name == "" || luaKeywords[name] {
+		return false
+	}
+	for i, r := range name {
+		if r != '_' && !(r >= 'a' && r <= 'z') && !(r >= 'A' && r <= 'Z') && !(i > 0 && r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,154 @@
+package luar
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/yuin/gopher-lua"
+)
+
+type stubsTestStatus int
+
+func (s stubsTestStatus) Valid() bool {
+	return s >= 0
+}
+
+type stubsTestAddress struct {
+	City string
+}
+
+type stubsTestPerson struct {
+	Name    string
+	Age     int    `luar:"years"`
+	Secret  string `luar:"-"`
+	Home    stubsTestAddress
+	Tags    []string
+	Friends []stubsTestPerson
+	Scores  map[string]float64
+	Status  stubsTestStatus
+	stubsTestAddress
+}
+
+func (p stubsTestPerson) Greet(greeting string, times int) string {
+	return strings.Repeat(greeting+" "+p.Name, times)
+}
+
+func (p *stubsTestPerson) Rename(name string) error {
+	p.Name = name
+	return nil
+}
+
+type stubsTestList []int
+
+func (l stubsTestList) Sum(extra ...int) int {
+	return 0
+}
+
+func writeStubs(t *testing.T, L *lua.LState, globals map[string]interface{}) string {
+	var b strings.Builder
+	if err := WriteStubs(&b, L, globals); err != nil {
+		t.Fatal(err)
+	}
+	return b.String()
+}
+
+func Test_stubs(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	out := writeStubs(t, L, map[string]interface{}{
+		"person": &stubsTestPerson{},
+		"Person": reflect.TypeOf(stubsTestPerson{}),
+		"find":   func(name string, limit int) ([]*stubsTestPerson, error) { return nil, nil },
+		"list":   stubsTestList{},
+		"c":      complex(1, 2),
+		"raw":    func(L *LState) int { return 0 },
+		"ch":     make(chan string),
+		"n":      lua.LNumber(1),
+		"my-var": "x",
+	})
+
+	for _, expected := range []string{
+		"---@meta\n",
+		"---@type luar.stubsTestPersonPtr\nperson = nil\n",
+		"---@type fun(): luar.stubsTestPersonPtr\nPerson = nil\n",
+		"---@param p1 string\n---@param p2 integer\n---@return luar.stubsTestPersonPtr[]\n---@return any\nfunction find(p1, p2) end\n",
+		"---@param ... any\n---@return any ...\nfunction raw(...) end\n",
+		"---@type number\nn = nil\n",
+		"---@type string\n_G[\"my-var\"] = nil\n",
+
+		"---@class luar.stubsTestPersonPtr\n",
+		"---@field Home luar.stubsTestAddressPtr\n",
+		"---@field city string\n",
+		"---@field friends luar.stubsTestPersonPtr[]\n",
+		"---@field scores table<string, number>\n",
+		"---@field years integer\n",
+		"---@operator pow(luar.stubsTestPerson|table): luar.stubsTestPersonPtr\n",
+		"---@operator unm: luar.stubsTestPerson\n",
+		"---@param p1 string\n---@return any\nfunction luar_stubsTestPersonPtr:rename(p1) end\n",
+
+		"---@class luar.stubsTestPerson\n",
+		"---@field home luar.stubsTestAddress\n",
+		"---@param p1 string\n---@param p2 integer\n---@return string\nfunction luar_stubsTestPerson:Greet(p1, p2) end\n",
+
+		"---@class luar.stubsTestList\n---@field [integer] integer\n---@operator add(integer): luar.stubsTestList\n---@operator len: integer\n---@operator call: fun(): integer, integer\n",
+		"---@param ... integer\n---@return integer\nfunction luar_stubsTestList:sum(...) end\n",
+
+		"---@operator mul(complex128|number): complex128\n",
+		"---@return complex128\nfunction complex128:conj() end\n",
+
+		"---@class chan_string\n---@operator unm: nil\n---@operator len: integer\n---@operator call: string, boolean\n",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Fatalf("expecting stubs to contain %q:\n%s", expected, out)
+		}
+	}
+
+	for _, unexpected := range []string{"Age", "Secret", "luar_stubsTestPerson:Rename", "luar.stubsTestStatus"} {
+		if strings.Contains(out, unexpected) {
+			t.Fatalf("unexpected %q in stubs:\n%s", unexpected, out)
+		}
+	}
+}
+
+func Test_stubs_config(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	config := GetConfig(L)
+	config.FieldNames = func(s reflect.Type, f reflect.StructField) []string {
+		return []string{"f_" + f.Name}
+	}
+	config.MethodNames = func(t reflect.Type, m reflect.Method) []string {
+		return []string{"m_" + m.Name, "m-" + m.Name}
+	}
+	config.NamedTypes = func(t reflect.Type) bool {
+		return true
+	}
+
+	out := writeStubs(t, L, map[string]interface{}{
+		"person": stubsTestPerson{},
+		"status": stubsTestStatus(1),
+	})
+
+	for _, expected := range []string{
+		"---@type luar.stubsTestPerson\nperson = nil\n",
+		"---@type luar.stubsTestStatus\nstatus = nil\n",
+		"---@field f_Name string\n",
+		"---@field f_Status luar.stubsTestStatus\n",
+		"function luar_stubsTestPerson:m_Greet(p1, p2) end\n",
+		"luar_stubsTestPerson[\"m-Greet\"] = function(self, p1, p2) end\n",
+		"---@class luar.stubsTestStatus\n",
+		"---@operator add(luar.stubsTestStatus|number): luar.stubsTestStatus\n",
+		"---@operator unm: luar.stubsTestStatus\n",
+		"---@return boolean\nfunction luar_stubsTestStatus:m_Valid() end\n",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Fatalf("expecting stubs to contain %q:\n%s", expected, out)
+		}
+	}
+	if strings.Contains(out, "field name") || strings.Contains(out, ":Greet(") {
+		t.Fatalf("unexpected default names in stubs:\n%s", out)
+	}
+}
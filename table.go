@@ -0,0 +1,123 @@
+package luar
+
+import (
+	"errors"
+	"reflect"
+
+	"github.com/yuin/gopher-lua"
+)
+
+var refTypeTable = reflect.TypeOf((*Table)(nil))
+
+// Table is a live view of a Lua table. Unlike converting a table to a Go map
+// or slice, which copies it, changes made through a Table are seen by Lua,
+// and changes made by Lua are seen through the Table.
+//
+// A Lua table passed to a Go function parameter (or converted to a struct
+// field, slice element, etc.) of type *Table is converted to a view of that
+// table, and a *Table converted by New is converted back to its table.
+//
+// Tables are accessed without invoking metamethods. A Table must only be used
+// while its state is not running Lua code on another goroutine.
+type Table struct {
+	l *lua.LState
+	t *lua.LTable
+}
+
+// NewTable returns a view of t, whose values are converted using L.
+func NewTable(L *lua.LState, t *lua.LTable) *Table {
+	return &Table{
+		l: L,
+		t: t,
+	}
+}
+
+// LTable returns the viewed table.
+func (t *Table) LTable() *lua.LTable {
+	return t.t
+}
+
+// Len returns the length of the table (i.e. the # operator, without
+// invoking the __len metamethod).
+func (t *Table) Len() int {
+	return t.t.Len()
+}
+
+// Has returns true if the table has a non-nil value for key, which is
+// converted using New.
+func (t *Table) Has(key interface{}) bool {
+	return t.Value(key) != lua.LNil
+}
+
+// Value returns the value of the table for key, which is converted using
+// New.
+func (t *Table) Value(key interface{}) lua.LValue {
+	return t.t.RawGet(New(t.l, key))
+}
+
+// Get stores the value of the table for key, which is converted using New,
+// in the value pointed to by value, converting it like a Go function
+// argument. If the table has no value for key, value is left unchanged.
+func (t *Table) Get(key interface{}, value interface{}) error {
+	ptr := reflect.ValueOf(value)
+	if ptr.Kind() != reflect.Ptr || ptr.IsNil() {
+		return errors.New("luar: Get expects a non-nil pointer")
+	}
+
+	lv := t.Value(key)
+	if lv == lua.LNil {
+		return nil
+	}
+	converted, err := lValueToReflect(t.l, lv, ptr.Type().Elem(), nil)
+	if err != nil {
+		return err
+	}
+	ptr.Elem().Set(converted)
+	return nil
+}
+
+// Set sets the value of the table for key to value, which are both converted
+// using New. A nil value removes key from the table.
+func (t *Table) Set(key interface{}, value interface{}) {
+	t.t.RawSet(New(t.l, key), New(t.l, value))
+}
+
+// Append appends value, which is converted using New, to the end of the
+// table.
+func (t *Table) Append(value interface{}) {
+	t.t.Append(New(t.l, value))
+}
+
+// Range calls fn for each key and value of the table, until it returns false.
+// fn must be a function of the form func(K, V) bool, and the keys and values
+// are converted to K and V like Go function arguments. An error is returned
+// if fn is not such a function, or if a key or value cannot be converted.
+//
+// Like pairs, the table must not be modified while it is iterated, except to
+// remove or change the value of existing keys.
+func (t *Table) Range(fn interface{}) error {
+	ref := reflect.ValueOf(fn)
+	if !ref.IsValid() || ref.Kind() != reflect.Func || ref.IsNil() {
+		return errors.New("luar: Range expects a func(K, V) bool")
+	}
+	refType := ref.Type()
+	if refType.NumIn() != 2 || refType.NumOut() != 1 || refType.Out(0).Kind() != reflect.Bool {
+		return errors.New("luar: Range expects a func(K, V) bool")
+	}
+	keyType, valueType := refType.In(0), refType.In(1)
+
+	for key, value := t.t.Next(lua.LNil); key != lua.LNil; key, value = t.t.Next(key) {
+		k, err := lValueToReflect(t.l, key, keyType, nil)
+		if err != nil {
+			return err
+		}
+		v, err := lValueToReflect(t.l, value, valueType, nil)
+		if err != nil {
+			return err
+		}
+		if !ref.Call([]reflect.Value{k, v})[0].Bool() {
+			break
+		}
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package luar
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/yuin/gopher-lua"
+)
+
+func Test_table_view(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	var view *Table
+	L.SetGlobal("keep", New(L, func(t *Table) {
+		view = t
+	}))
+	L.SetGlobal("get", New(L, func() *Table {
+		return view
+	}))
+
+	testReturn(t, L, `tbl = {name = "a", 1, 2, 3}; keep(tbl)`)
+	if view.LTable() != L.GetGlobal("tbl") {
+		t.Fatal("expecting a view of the same table")
+	}
+
+	// changes from Go are seen by Lua
+	view.Set("name", "b")
+	view.Set(2, 20)
+	view.Append(4)
+	view.Set("missing", nil)
+	testReturn(t, L, `return tbl.name, tbl[2], #tbl, tbl[4]`, "b", "20", "4", "4")
+
+	// changes from Lua are seen by Go
+	testReturn(t, L, `tbl.name = "c"; tbl[5] = 5`)
+	var name string
+	if err := view.Get("name", &name); err != nil || name != "c" {
+		t.Fatalf("unexpected name %q (%v)", name, err)
+	}
+	if view.Len() != 5 {
+		t.Fatalf("expecting length 5, got %d", view.Len())
+	}
+
+	// converted back to the same table
+	testReturn(t, L, `return rawequal(get(), tbl)`, "true")
+}
+
+func Test_table_get(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	if err := L.DoString(`tbl = {n = 1.5, list = {1, 2}, sub = {x = true}, s = "x"}`); err != nil {
+		t.Fatal(err)
+	}
+	view := NewTable(L, L.GetGlobal("tbl").(*lua.LTable))
+
+	var n float64
+	var list []int
+	var sub struct{ X bool }
+	var inner *Table
+	for key, value := range map[string]interface{}{"n": &n, "list": &list, "sub": &sub} {
+		if err := view.Get(key, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n != 1.5 || !reflect.DeepEqual(list, []int{1, 2}) || !sub.X {
+		t.Fatalf("unexpected values %v %v %v", n, list, sub)
+	}
+
+	if err := view.Get("sub", &inner); err != nil {
+		t.Fatal(err)
+	}
+	inner.Set("x", false)
+	testReturn(t, L, `return tbl.sub.x`, "false")
+
+	missing := 42
+	if err := view.Get("missing", &missing); err != nil || missing != 42 {
+		t.Fatalf("expecting missing value to be left unchanged, got %d (%v)", missing, err)
+	}
+	if view.Has("missing") || !view.Has("n") {
+		t.Fatal("unexpected Has result")
+	}
+	if err := view.Get("s", &n); err == nil {
+		t.Fatal("expecting conversion error")
+	}
+	if err := view.Get("n", n); err == nil {
+		t.Fatal("expecting error for non-pointer")
+	}
+}
+
+func Test_table_range(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	if err := L.DoString(`tbl = {a = 1, b = 2, c = 3}`); err != nil {
+		t.Fatal(err)
+	}
+	view := NewTable(L, L.GetGlobal("tbl").(*lua.LTable))
+
+	var keys []string
+	sum := 0
+	if err := view.Range(func(key string, value int) bool {
+		keys = append(keys, key)
+		sum += value
+		return true
+	}); err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(keys)
+	if strings.Join(keys, ",") != "a,b,c" || sum != 6 {
+		t.Fatalf("unexpected keys %v and sum %d", keys, sum)
+	}
+
+	count := 0
+	if err := view.Range(func(key, value lua.LValue) bool {
+		count++
+		return false
+	}); err != nil || count != 1 {
+		t.Fatalf("expecting iteration to stop, got %d (%v)", count, err)
+	}
+
+	if err := view.Range(func(key string, value bool) bool { return true }); err == nil {
+		t.Fatal("expecting conversion error")
+	}
+	if err := view.Range(nil); err == nil {
+		t.Fatal("expecting error for nil")
+	}
+	if err := view.Range((func(key string, value int) bool)(nil)); err == nil {
+		t.Fatal("expecting error for nil func")
+	}
+	if err := view.Range(func(key string) {}); err == nil {
+		t.Fatal("expecting invalid function error")
+	}
+}
+
+func Test_table_field(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	type Options struct {
+		Extra *Table
+	}
+	var got Options
+	L.SetGlobal("configure", New(L, func(o Options) {
+		got = o
+	}))
+
+	testReturn(t, L, `extra = {}; configure({extra = extra})`)
+	got.Extra.Set("seen", true)
+	testReturn(t, L, `return extra.seen`, "true")
+}
@@ -0,0 +1,61 @@
+package luar
+
+import (
+	"context"
+
+	"github.com/yuin/gopher-lua"
+)
+
+// maxIdleThreads is the maximum number of idle threads a state keeps for
+// calling Lua functions from Go.
+const maxIdleThreads = 16
+
+// callThread is a thread used to call a Lua function from a Go function
+// created by lValueToReflect.
+type callThread struct {
+	*lua.LState
+	// the context of the parent state when the thread was created
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// getThread returns an idle thread of L, or a new thread if there are none
+// that can be reused. Threads are only reused if they were created with the
+// same context and environment as L currently has.
+func getThread(L *lua.LState, c *Config) callThread {
+	ctx := L.Context()
+	for n := len(c.threads); n > 0; n = len(c.threads) {
+		t := c.threads[n-1]
+		c.threads = c.threads[:n-1]
+		if t.ctx == ctx && t.Env == L.Env {
+			return t
+		}
+		t.release()
+	}
+
+	thread, cancel := L.NewThread()
+	return callThread{
+		LState: thread,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// putThread clears the stack of t, which must have completed its call, and
+// keeps it for reuse.
+func putThread(c *Config, t callThread) {
+	t.SetTop(0)
+	if len(c.threads) >= maxIdleThreads {
+		t.release()
+		return
+	}
+	c.threads = append(c.threads, t)
+}
+
+// release cancels the context of t. The thread is not closed, as closing a
+// thread removes the temporary files of the whole state.
+func (t callThread) release() {
+	if t.cancel != nil {
+		t.cancel()
+	}
+}
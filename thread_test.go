@@ -0,0 +1,106 @@
+package luar
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/yuin/gopher-lua"
+)
+
+func Test_thread_reuse(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	var callbacks []func(int) int
+	L.SetGlobal("register", New(L, func(fn func(int) int) {
+		callbacks = append(callbacks, fn)
+	}))
+
+	testReturn(t, L, `
+		register(function(i) return i * 2 end)
+		register(function(i) error("failed " .. i) end)
+		return
+	`)
+
+	config := GetConfig(L)
+	for i := 0; i < 3; i++ {
+		if ret := callbacks[0](i); ret != i*2 {
+			t.Fatalf("expecting %d, got %d", i*2, ret)
+		}
+		if n := len(config.threads); n != 1 {
+			t.Fatalf("expecting 1 idle thread, got %d", n)
+		}
+		if top := config.threads[0].GetTop(); top != 0 {
+			t.Fatalf("expecting empty stack, got %d values", top)
+		}
+	}
+
+	func() {
+		defer func() {
+			if rcv := recover(); rcv == nil || !strings.Contains(rcv.(error).Error(), "failed 1") {
+				t.Fatalf("expecting error, got %v", rcv)
+			}
+		}()
+		callbacks[1](1)
+	}()
+	if n := len(config.threads); n != 0 {
+		t.Fatalf("expecting thread not to be reused after an error, got %d idle", n)
+	}
+
+	if ret := callbacks[0](5); ret != 10 {
+		t.Fatalf("expecting 10, got %d", ret)
+	}
+}
+
+func Test_thread_nested(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	L.SetGlobal("sort", New(L, func(s []string, less func(a, b string) bool) {
+		sort.Slice(s, func(i, j int) bool {
+			return less(s[i], s[j])
+		})
+	}))
+	L.SetGlobal("apply", New(L, func(fn func() string) string {
+		return fn()
+	}))
+
+	testReturn(t, L, `
+		return apply(function()
+			return "outer " .. apply(function() return "inner" end)
+		end)
+	`, "outer inner")
+
+	L.SetGlobal("s", New(L, []string{"c", "a", "b"}))
+	testReturn(t, L, `
+		sort(s, function(a, b)
+			return apply(function() return a end) < b
+		end)
+		return s[1], s[2], s[3]
+	`, "a", "b", "c")
+}
+
+func Test_thread_context(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	var callback func() string
+	L.SetGlobal("register", New(L, func(fn func() string) {
+		callback = fn
+	}))
+	testReturn(t, L, `register(function() return "called" end)`)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	L.SetContext(ctx)
+	if ret := callback(); ret != "called" {
+		t.Fatalf("expecting called, got %s", ret)
+	}
+	cancel()
+
+	L.SetContext(context.Background())
+	if ret := callback(); ret != "called" {
+		t.Fatalf("expecting called, got %s", ret)
+	}
+}
@@ -46,6 +46,12 @@ func typeCall(L *lua.LState) int {
 		value = reflect.MakeSlice(ref, length, capacity)
 	default:
 		value = reflect.New(ref)
+		if isBigType(value.Type()) && L.GetTop() >= 2 {
+			var ok bool
+			if value, ok = bigFromLValue(L.Get(2), value.Type()); !ok {
+				L.ArgError(2, "cannot convert to "+ref.String())
+			}
+		}
 	}
 	L.Push(New(L, value.Interface()))
 	return 1
@@ -0,0 +1,162 @@
+package luar
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/yuin/gopher-lua"
+)
+
+// UnknownKeyPolicy defines how the keys of a table that are not the names of
+// struct fields are handled when the table is converted to a struct (or
+// merged into one by Merge).
+type UnknownKeyPolicy int
+
+const (
+	// UnknownKeysDefault returns an error for unknown string keys, and
+	// ignores other keys (e.g. the array part of the table).
+	UnknownKeysDefault UnknownKeyPolicy = iota
+
+	// UnknownKeysIgnore ignores unknown keys.
+	UnknownKeysIgnore
+
+	// UnknownKeysError returns an error for any unknown key, including
+	// numeric keys.
+	UnknownKeysError
+
+	// UnknownKeysCollect stores the values of unknown string and numeric
+	// keys in the struct's extra field: a field whose "luar" tag has the
+	// "extra" option (e.g. `luar:",extra"`), and whose type is a map with
+	// string keys, such as map[string]interface{}. Numeric keys are
+	// converted to strings as Lua's tostring does, and values are converted
+	// to the map's element type. Unknown keys of structs without an extra
+	// field, and keys of other types, are ignored.
+	//
+	// Like the inline fields of encoding/json, the extra field has no name,
+	// whatever the policy or Config.FieldNames: a key equal to the Go name
+	// of the field is an unknown key like any other.
+	UnknownKeysCollect
+)
+
+// unknownKey handles key, which is not the name of a field of the struct dst,
+// according to the policy of config. Collected values are converted using
+// visited.
+func unknownKey(L *lua.LState, config *Config, dst reflect.Value, key, value lua.LValue, visited map[*lua.LTable]reflect.Value) error {
+	switch config.UnknownKeys {
+	case UnknownKeysIgnore:
+		return nil
+	case UnknownKeysCollect:
+		return collectKey(L, config, dst, key, value, visited)
+	}
+
+	if _, ok := key.(lua.LString); ok || config.UnknownKeys == UnknownKeysError {
+		return structFieldError{
+			Type:  dst.Type(),
+			Field: key.String(),
+		}
+	}
+	return nil
+}
+
+func collectKey(L *lua.LState, config *Config, dst reflect.Value, key, value lua.LValue, visited map[*lua.LTable]reflect.Value) error {
+	index := extraField(config, dst.Type())
+	if index == nil {
+		return nil
+	}
+	var name string
+	switch converted := key.(type) {
+	case lua.LString:
+		name = string(converted)
+	case lua.LNumber:
+		name = converted.String()
+	default:
+		return nil
+	}
+
+	field := mergeField(dst, index)
+	if !field.CanSet() {
+		return structFieldError{
+			Type:  dst.Type(),
+			Field: name,
+		}
+	}
+	if field.IsNil() {
+		field.Set(reflect.MakeMap(field.Type()))
+	}
+	val, err := lValueToReflectInner(L, value, field.Type().Elem(), visited, nil)
+	if err != nil {
+		return err
+	}
+	field.SetMapIndex(reflect.ValueOf(name).Convert(field.Type().Key()), val)
+	return nil
+}
+
+// extraField returns the index of the extra field of the struct type t (see
+// UnknownKeysCollect), or nil if it has none, using the registry of c if it
+// has one.
+func extraField(c *Config, t reflect.Type) []int {
+	if c.Registry != nil {
+		return c.Registry.lookup(t).extra
+	}
+	return typeExtraField(t)
+}
+
+// isExtraField returns true if field may be the extra field of a struct.
+func isExtraField(field reflect.StructField) bool {
+	return hasTagOption(field.Tag.Get("luar"), "extra") && field.Type.Kind() == reflect.Map && field.Type.Key().Kind() == reflect.String
+}
+
+var extraFields sync.Map // map[reflect.Type][]int
+
+// typeExtraField returns the index of the extra field of the struct type t.
+// If several fields are tagged, the least deeply embedded one is used.
+func typeExtraField(t reflect.Type) []int {
+	if index, ok := extraFields.Load(t); ok {
+		return index.([]int)
+	}
+
+	var index []int
+	for _, field := range collectFields(t, nil) {
+		if !isExtraField(field) {
+			continue
+		}
+		if index == nil || len(field.Index) < len(index) || (len(field.Index) == len(index) && indexLess(field.Index, index)) {
+			index = field.Index
+		}
+	}
+	extraFields.Store(t, index)
+	return index
+}
+
+func indexLess(a, b []int) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return false
+}
+
+// tagNameOnly returns the name of a "luar" tag, without its options.
+func tagNameOnly(tag string) string {
+	if i := strings.IndexByte(tag, ','); i >= 0 {
+		return tag[:i]
+	}
+	return tag
+}
+
+// hasTagOption returns true if the "luar" tag has the given option (e.g.
+// "extra" in `luar:"name,extra"`).
+func hasTagOption(tag, option string) bool {
+	i := strings.IndexByte(tag, ',')
+	if i < 0 {
+		return false
+	}
+	for _, opt := range strings.Split(tag[i+1:], ",") {
+		if opt == option {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,162 @@
+package luar
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/yuin/gopher-lua"
+)
+
+type unknownTestOptions struct {
+	Name  string
+	Extra map[string]interface{} `luar:",extra"`
+}
+
+type unknownTestConfig struct {
+	Port    int
+	Options *unknownTestOptions
+}
+
+type unknownTestEmbedded struct {
+	*UnknownTestBase
+	Name string
+}
+
+type UnknownTestBase struct {
+	Rest map[string]string `luar:"rest,extra"`
+}
+
+func unknownConvert(t *testing.T, L *lua.LState, code string, hint reflect.Type) (reflect.Value, error) {
+	t.Helper()
+	return lValueToReflect(L, mergeTable(t, L, code), hint, nil)
+}
+
+func Test_unknown_policies(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+	config := GetConfig(L)
+	hint := reflect.TypeOf(unknownTestConfig{})
+
+	for _, test := range []struct {
+		policy UnknownKeyPolicy
+		code   string
+		err    string
+	}{
+		{UnknownKeysDefault, `{port = 1, [1] = true}`, ""},
+		{UnknownKeysDefault, `{port = 1, host = "x"}`, "type luar.unknownTestConfig has no field host"},
+		{UnknownKeysIgnore, `{port = 1, host = "x", "a"}`, ""},
+		{UnknownKeysError, `{port = 1}`, ""},
+		{UnknownKeysError, `{port = 1, "a"}`, "type luar.unknownTestConfig has no field 1"},
+		{UnknownKeysError, `{port = 1, [true] = 1}`, "type luar.unknownTestConfig has no field true"},
+		{UnknownKeysCollect, `{port = 1, host = "x", "a"}`, ""},
+	} {
+		config.UnknownKeys = test.policy
+		val, err := unknownConvert(t, L, test.code, hint)
+		if test.err == "" {
+			if err != nil {
+				t.Fatalf("%d %s: unexpected error %v", test.policy, test.code, err)
+			}
+			if val.Interface().(unknownTestConfig).Port != 1 {
+				t.Fatalf("%d %s: unexpected value %v", test.policy, test.code, val)
+			}
+		} else if err == nil || err.Error() != test.err {
+			t.Fatalf("%d %s: expecting error %q, got %v", test.policy, test.code, test.err, err)
+		}
+	}
+}
+
+func Test_unknown_collect(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+	GetConfig(L).UnknownKeys = UnknownKeysCollect
+
+	val, err := unknownConvert(t, L, `{port = 1, options = {name = "x", color = "red", size = 2, "first"}}`, reflect.TypeOf(unknownTestConfig{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &unknownTestOptions{
+		Name: "x",
+		Extra: map[string]interface{}{
+			"color": "red",
+			"size":  float64(2),
+			"1":     "first",
+		},
+	}
+	if got := val.Interface().(unknownTestConfig).Options; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expecting %#v, got %#v", expected, got)
+	}
+
+	// the extra field has no name, so a key equal to it is collected
+	L.SetGlobal("o", New(L, expected))
+	testReturn(t, L, `return o.extra, o.Extra`, "nil", "nil")
+	val, err = unknownConvert(t, L, `{name = "x", extra = 5}`, reflect.TypeOf(unknownTestOptions{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if extra := val.Interface().(unknownTestOptions).Extra; !reflect.DeepEqual(extra, map[string]interface{}{"extra": float64(5)}) {
+		t.Fatalf("unexpected extra values %#v", extra)
+	}
+	GetConfig(L).Registry = NewTypeRegistry(nil, nil)
+	val, err = unknownConvert(t, L, `{name = "x", rest = 5}`, reflect.TypeOf(unknownTestOptions{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if extra := val.Interface().(unknownTestOptions).Extra; !reflect.DeepEqual(extra, map[string]interface{}{"rest": float64(5)}) {
+		t.Fatalf("unexpected extra values with a registry %#v", extra)
+	}
+	GetConfig(L).Registry = nil
+
+	// extra fields of embedded struct pointers, with their element type
+	val, err = unknownConvert(t, L, `{name = "x", color = "red", [true] = "ignored"}`, reflect.TypeOf(unknownTestEmbedded{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	embedded := val.Interface().(unknownTestEmbedded)
+	if embedded.UnknownTestBase == nil || !reflect.DeepEqual(embedded.Rest, map[string]string{"color": "red"}) {
+		t.Fatalf("unexpected value %#v", embedded)
+	}
+	if _, err := unknownConvert(t, L, `{color = 1}`, reflect.TypeOf(unknownTestEmbedded{})); err == nil {
+		t.Fatal("expecting error converting an extra value to the element type")
+	}
+}
+
+func Test_unknown_merge(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	c := unknownTestConfig{Options: &unknownTestOptions{Extra: map[string]interface{}{"a": "b"}}}
+	if err := Merge(L, mergeTable(t, L, `{options = {color = "red"}}`), &c); err == nil {
+		t.Fatal("expecting error for unknown key")
+	}
+
+	GetConfig(L).UnknownKeys = UnknownKeysCollect
+	if err := Merge(L, mergeTable(t, L, `{options = {color = "red"}}`), &c); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{"a": "b", "color": "red"}
+	if !reflect.DeepEqual(c.Options.Extra, expected) {
+		t.Fatalf("expecting %#v, got %#v", expected, c.Options.Extra)
+	}
+}
+
+func Test_unknown_tag(t *testing.T) {
+	for _, test := range []struct {
+		tag    string
+		name   string
+		option bool
+	}{
+		{"", "", false},
+		{"name", "name", false},
+		{",extra", "", true},
+		{"name,extra", "name", true},
+		{"name,other,extra", "name", true},
+		{"extra", "extra", false},
+	} {
+		if name := tagNameOnly(test.tag); name != test.name {
+			t.Fatalf("%q: expecting name %q, got %q", test.tag, test.name, name)
+		}
+		if option := hasTagOption(test.tag, "extra"); option != test.option {
+			t.Fatalf("%q: expecting option %v, got %v", test.tag, test.option, option)
+		}
+	}
+}
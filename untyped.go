@@ -0,0 +1,83 @@
+package luar
+
+import (
+	"math"
+	"reflect"
+
+	"github.com/yuin/gopher-lua"
+)
+
+// EmptyTablePolicy defines what an empty table is converted to when
+// Config.IdiomaticUntyped is set.
+type EmptyTablePolicy int
+
+const (
+	// EmptyTableMap converts empty tables to an empty
+	// map[string]interface{}.
+	EmptyTableMap EmptyTablePolicy = iota
+
+	// EmptyTableSlice converts empty tables to an empty []interface{}.
+	EmptyTableSlice
+
+	// EmptyTableNil converts empty tables to nil.
+	EmptyTableNil
+)
+
+var (
+	refTypeUntypedSlice = reflect.TypeOf([]interface{}(nil))
+	refTypeUntypedMap   = reflect.TypeOf(map[string]interface{}(nil))
+)
+
+// untypedNumber converts n to an int64 if it is integral and fits in one, and
+// to a float64 otherwise.
+func untypedNumber(n lua.LNumber) interface{} {
+	f := float64(n)
+	if f >= math.MinInt64 && f < math.MaxInt64 && f == math.Trunc(f) {
+		return int64(f)
+	}
+	return f
+}
+
+// untypedTableType returns the type that tbl is converted to when
+// Config.IdiomaticUntyped is set, or nil if it is converted to nil.
+func untypedTableType(config *Config, tbl *lua.LTable) reflect.Type {
+	var (
+		count    int
+		max      lua.LNumber
+		sequence = true
+		strings  = true
+	)
+	tbl.ForEach(func(key, _ lua.LValue) {
+		count++
+		switch k := key.(type) {
+		case lua.LNumber:
+			strings = false
+			if k < 1 || k != lua.LNumber(math.Trunc(float64(k))) {
+				sequence = false
+			} else if k > max {
+				max = k
+			}
+		case lua.LString:
+			sequence = false
+		default:
+			sequence = false
+			strings = false
+		}
+	})
+
+	switch {
+	case count == 0:
+		switch config.EmptyTables {
+		case EmptyTableSlice:
+			return refTypeUntypedSlice
+		case EmptyTableNil:
+			return nil
+		}
+		return refTypeUntypedMap
+	case sequence && max == lua.LNumber(count):
+		return refTypeUntypedSlice
+	case strings:
+		return refTypeUntypedMap
+	}
+	return reflect.MapOf(refTypeEmptyIface, refTypeEmptyIface)
+}
@@ -0,0 +1,138 @@
+package luar
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/yuin/gopher-lua"
+)
+
+func untypedDecode(t *testing.T, L *lua.LState, script string) interface{} {
+	var got interface{}
+	L.SetGlobal("decode", New(L, func(v interface{}) {
+		got = v
+	}))
+	if err := L.DoString(script); err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
+
+func Test_untyped_idiomatic(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+	GetConfig(L).IdiomaticUntyped = true
+
+	got := untypedDecode(t, L, `decode({
+		name = "x",
+		count = 3,
+		ratio = 0.5,
+		list = {1, "two", {nested = true}},
+		empty = {},
+		mixed = {1, 2, key = "v"},
+	})`)
+
+	expected := map[string]interface{}{
+		"name":  "x",
+		"count": int64(3),
+		"ratio": 0.5,
+		"list": []interface{}{
+			int64(1),
+			"two",
+			map[string]interface{}{"nested": true},
+		},
+		"empty": map[string]interface{}{},
+		"mixed": map[interface{}]interface{}{
+			int64(1): int64(1),
+			int64(2): int64(2),
+			"key":    "v",
+		},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expecting %#v, got %#v", expected, got)
+	}
+
+	// the values can be encoded as JSON, except for the mixed table
+	delete(got.(map[string]interface{}), "mixed")
+	b, err := json.Marshal(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const expectedJSON = `{"count":3,"empty":{},"list":[1,"two",{"nested":true}],"name":"x","ratio":0.5}`
+	if string(b) != expectedJSON {
+		t.Fatalf("expecting %s, got %s", expectedJSON, b)
+	}
+}
+
+func Test_untyped_numbers(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+	GetConfig(L).IdiomaticUntyped = true
+
+	got := untypedDecode(t, L, `decode({1, -2, 2^53, 1.5, 2^63, 1/0})`)
+	expected := []interface{}{int64(1), int64(-2), int64(1 << 53), 1.5, float64(1 << 63), math.Inf(1)}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expecting %#v, got %#v", expected, got)
+	}
+}
+
+func Test_untyped_sequence(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+	GetConfig(L).IdiomaticUntyped = true
+
+	for script, expected := range map[string]reflect.Type{
+		`decode({[1] = "a", [2] = "b"})`:               refTypeUntypedSlice,
+		`local t = {} t[2] = "b" t[1] = "a" decode(t)`: refTypeUntypedSlice,
+		`decode({[1] = "a", [3] = "c"})`:               reflect.TypeOf(map[interface{}]interface{}{}),
+		`decode({[0] = "a", [1] = "b"})`:               reflect.TypeOf(map[interface{}]interface{}{}),
+		`decode({[1.5] = "a"})`:                        reflect.TypeOf(map[interface{}]interface{}{}),
+		`decode({[true] = "a"})`:                       reflect.TypeOf(map[interface{}]interface{}{}),
+	} {
+		if got := reflect.TypeOf(untypedDecode(t, L, script)); got != expected {
+			t.Fatalf("%s: expecting %s, got %s", script, expected, got)
+		}
+	}
+}
+
+func Test_untyped_empty(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+	config := GetConfig(L)
+	config.IdiomaticUntyped = true
+
+	for policy, expected := range map[EmptyTablePolicy]interface{}{
+		EmptyTableMap:   map[string]interface{}{},
+		EmptyTableSlice: []interface{}{},
+		EmptyTableNil:   nil,
+	} {
+		config.EmptyTables = policy
+		if got := untypedDecode(t, L, `decode({})`); !reflect.DeepEqual(got, expected) {
+			t.Fatalf("%d: expecting %#v, got %#v", policy, expected, got)
+		}
+	}
+}
+
+func Test_untyped_cycle(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+	GetConfig(L).IdiomaticUntyped = true
+
+	got := untypedDecode(t, L, `local t = {name = "a"} t.self = t decode(t)`).(map[string]interface{})
+	if self, ok := got["self"].(map[string]interface{}); !ok || self["name"] != "a" {
+		t.Fatalf("unexpected value %#v", got)
+	}
+}
+
+func Test_untyped_default(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	got := untypedDecode(t, L, `decode({1, x = 2})`)
+	expected := map[interface{}]interface{}{float64(1): float64(1), "x": float64(2)}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expecting %#v, got %#v", expected, got)
+	}
+}
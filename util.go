@@ -9,10 +9,12 @@ import (
 	"github.com/yuin/gopher-lua"
 )
 
-func check(L *lua.LState, idx int) (ref reflect.Value, mt *Metatable) {
+// check returns the value of the userdata at idx, and its metatable. The
+// metatable is returned by value so that checking does not allocate.
+func check(L *lua.LState, idx int) (ref reflect.Value, mt Metatable) {
 	ud := L.CheckUserData(idx)
 	ref = reflect.ValueOf(ud.Value)
-	mt = &Metatable{LTable: ud.Metatable.(*lua.LTable)}
+	mt = Metatable{LTable: ud.Metatable.(*lua.LTable)}
 	return
 }
 
@@ -20,6 +22,8 @@ func tostring(L *lua.LState) int {
 	ud := L.CheckUserData(1)
 	if stringer, ok := ud.Value.(fmt.Stringer); ok {
 		L.Push(lua.LString(stringer.String()))
+	} else if err, ok := ud.Value.(error); ok {
+		L.Push(lua.LString(err.Error()))
 	} else {
 		L.Push(lua.LString(ud.String()))
 	}
@@ -0,0 +1,266 @@
+package luar
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/yuin/gopher-lua"
+)
+
+// Defaulter is implemented by struct types (or pointers to them) that set
+// default field values. When a table is converted to such a struct (or a
+// pointer to one), or Merge allocates one, LuarDefaults is called on a
+// pointer to the new zero value before the table's fields are stored in it.
+type Defaulter interface {
+	LuarDefaults()
+}
+
+// Validator is implemented by struct types (or pointers to them) that check
+// their invariants. When a table is converted to such a struct (or a pointer
+// to one), or merged into it by Merge, LuarValidate is called once all of the
+// table's fields are stored, after the structs nested in it are validated.
+//
+// Only the structs that tables are converted or merged into are validated: a
+// nested struct that the table does not mention (e.g. one set by the
+// LuarDefaults method of the struct containing it) is not, so its invariants
+// should be checked by the LuarValidate method of the containing struct.
+//
+// If any LuarValidate method returns an error, the conversion fails with a
+// *ValidationError holding every violation. A LuarValidate method may itself
+// return a *ValidationError, whose paths are relative to the struct, to
+// report several violations.
+type Validator interface {
+	LuarValidate() error
+}
+
+var refTypeValidator = reflect.TypeOf((*Validator)(nil)).Elem()
+
+// Violation is an error returned by a LuarValidate method.
+type Violation struct {
+	// The path of the struct that was validated, relative to the converted
+	// value, using the keys of the converted tables: e.g. "server" or
+	// "servers[2].tls" (with Lua indexes, starting at 1). It is "" for the
+	// converted value itself.
+	Path string
+	Err  error
+}
+
+// ValidationError is returned when a value converted from a table fails
+// validation (see Validator).
+type ValidationError struct {
+	Violations []Violation
+}
+
+func (v *ValidationError) Error() string {
+	var b strings.Builder
+	b.WriteString("validation failed: ")
+	for i, violation := range v.Violations {
+		if i > 0 {
+			b.WriteString("; ")
+		}
+		if violation.Path != "" {
+			b.WriteString(violation.Path)
+			b.WriteString(": ")
+		}
+		b.WriteString(violation.Err.Error())
+	}
+	return b.String()
+}
+
+// setDefaults calls the LuarDefaults method of ptr, a pointer to a new
+// struct, if it has one.
+func setDefaults(ptr reflect.Value) {
+	if d, ok := ptr.Interface().(Defaulter); ok {
+		d.LuarDefaults()
+	}
+}
+
+// validate calls the LuarValidate methods of val, converted from tbl, and of
+// the values converted from tbl's nested tables. A *ValidationError is
+// returned if any of them fails.
+func validate(L *lua.LState, tbl *lua.LTable, val reflect.Value) error {
+	if !needsValidation(val.Type()) {
+		return nil
+	}
+	v := &validator{
+		l:       L,
+		visited: make(map[*lua.LTable]bool),
+	}
+	v.walk(tbl, val, "")
+	if len(v.violations) > 0 {
+		return &ValidationError{
+			Violations: v.violations,
+		}
+	}
+	return nil
+}
+
+type validator struct {
+	l          *lua.LState
+	visited    map[*lua.LTable]bool
+	violations []Violation
+}
+
+func (v *validator) walk(tbl *lua.LTable, val reflect.Value, path string) {
+	if v.visited[tbl] || !needsValidation(val.Type()) {
+		return
+	}
+	v.visited[tbl] = true
+
+	switch val.Kind() {
+	case reflect.Ptr:
+		if !val.IsNil() {
+			v.walkStruct(tbl, val.Elem(), path)
+		}
+	case reflect.Struct:
+		v.walkStruct(tbl, val, path)
+	case reflect.Array, reflect.Slice:
+		for i, length := 1, tbl.Len(); i <= length && i <= val.Len(); i++ {
+			if nested, ok := tbl.RawGetInt(i).(*lua.LTable); ok {
+				v.walk(nested, val.Index(i-1), path+"["+strconv.Itoa(i)+"]")
+			}
+		}
+	case reflect.Map:
+		for key, value := tbl.Next(lua.LNil); key != lua.LNil; key, value = tbl.Next(key) {
+			nested, ok := value.(*lua.LTable)
+			if !ok {
+				continue
+			}
+			k, err := lValueToReflect(v.l, key, val.Type().Key(), nil)
+			if err != nil {
+				continue
+			}
+			current := val.MapIndex(k)
+			if !current.IsValid() {
+				continue
+			}
+			// map values are not addressable, so a copy is validated
+			elem := reflect.New(current.Type()).Elem()
+			elem.Set(current)
+			v.walk(nested, elem, validationPath(path, key))
+		}
+	}
+}
+
+func (v *validator) walkStruct(tbl *lua.LTable, val reflect.Value, path string) {
+	mt := &Metatable{
+		LTable: getMetatable(v.l, val.Type()),
+	}
+	for key, value := tbl.Next(lua.LNil); key != lua.LNil; key, value = tbl.Next(key) {
+		nested, ok := value.(*lua.LTable)
+		if !ok {
+			continue
+		}
+		name, ok := key.(lua.LString)
+		if !ok {
+			continue
+		}
+		accessor := mt.field(string(name))
+		if accessor == nil {
+			continue
+		}
+		field, ok := validationField(val, accessor.index)
+		if ok {
+			v.walk(nested, field, validationPath(path, key))
+		}
+	}
+
+	receiver := val
+	if val.CanAddr() {
+		receiver = val.Addr()
+	}
+	validator, ok := receiver.Interface().(Validator)
+	if !ok {
+		return
+	}
+	err := validator.LuarValidate()
+	if err == nil {
+		return
+	}
+	if nested, ok := err.(*ValidationError); ok {
+		for _, violation := range nested.Violations {
+			if violation.Path == "" {
+				violation.Path = path
+			} else if path != "" {
+				if strings.HasPrefix(violation.Path, "[") {
+					violation.Path = path + violation.Path
+				} else {
+					violation.Path = path + "." + violation.Path
+				}
+			}
+			v.violations = append(v.violations, violation)
+		}
+		return
+	}
+	v.violations = append(v.violations, Violation{
+		Path: path,
+		Err:  err,
+	})
+}
+
+// validationField returns the field of the struct val with the given index,
+// or false if it is promoted through a nil embedded pointer.
+func validationField(val reflect.Value, index []int) (reflect.Value, bool) {
+	for i, fieldIndex := range index {
+		if i > 0 && val.Kind() == reflect.Ptr {
+			if val.IsNil() {
+				return reflect.Value{}, false
+			}
+			val = val.Elem()
+		}
+		val = val.Field(fieldIndex)
+	}
+	return val, true
+}
+
+// validationPath returns the path of the value at key of the value at path.
+func validationPath(path string, key lua.LValue) string {
+	if s, ok := key.(lua.LString); ok && isLuaIdentifier(string(s)) {
+		if path == "" {
+			return string(s)
+		}
+		return path + "." + string(s)
+	}
+	if s, ok := key.(lua.LString); ok {
+		return path + "[" + strconv.Quote(string(s)) + "]"
+	}
+	return path + "[" + key.String() + "]"
+}
+
+var validationTypes sync.Map // map[reflect.Type]bool
+
+// needsValidation returns true if values of type t may contain structs that
+// implement Validator, and that can be converted from tables.
+func needsValidation(t reflect.Type) bool {
+	if needs, ok := validationTypes.Load(t); ok {
+		return needs.(bool)
+	}
+	needs := typeNeedsValidation(t, make(map[reflect.Type]bool))
+	validationTypes.Store(t, needs)
+	return needs
+}
+
+func typeNeedsValidation(t reflect.Type, seen map[reflect.Type]bool) bool {
+	if seen[t] || !mergeable(t) {
+		return false
+	}
+	seen[t] = true
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return typeNeedsValidation(t.Elem(), seen)
+	case reflect.Struct:
+		if reflect.PtrTo(t).Implements(refTypeValidator) {
+			return true
+		}
+		for i := 0; i < t.NumField(); i++ {
+			if typeNeedsValidation(t.Field(i).Type, seen) {
+				return true
+			}
+		}
+		return false
+	}
+	return typeNeedsValidation(t.Elem(), seen)
+}
@@ -0,0 +1,165 @@
+package luar
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/yuin/gopher-lua"
+)
+
+type validateServer struct {
+	Host string
+	Port int
+}
+
+func (s *validateServer) LuarDefaults() {
+	s.Host = "localhost"
+	s.Port = 80
+}
+
+func (s *validateServer) LuarValidate() error {
+	if s.Port <= 0 || s.Port > 65535 {
+		return errors.New("port out of range")
+	}
+	return nil
+}
+
+type validateConfig struct {
+	Name    string
+	Primary validateServer
+	Backup  *validateServer
+	Servers []validateServer
+	Zones   map[string]*validateServer
+}
+
+func (c validateConfig) LuarValidate() error {
+	var violations []Violation
+	if c.Name == "" {
+		violations = append(violations, Violation{Path: "name", Err: errors.New("required")})
+	}
+	if len(c.Servers) > 2 {
+		violations = append(violations, Violation{Path: "servers", Err: errors.New("too many servers")})
+	}
+	if len(violations) > 0 {
+		return &ValidationError{Violations: violations}
+	}
+	return nil
+}
+
+func Test_validate_defaults(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	var c validateConfig
+	L.SetGlobal("set", New(L, func(v validateConfig) {
+		c = v
+	}))
+
+	testReturn(t, L, `set({name = "x", primary = {port = 8080}, backup = {}, servers = {{host = "a"}}, zones = {eu = {}}})`)
+
+	expected := validateConfig{
+		Name:    "x",
+		Primary: validateServer{Host: "localhost", Port: 8080},
+		Backup:  &validateServer{Host: "localhost", Port: 80},
+		Servers: []validateServer{{Host: "a", Port: 80}},
+		Zones:   map[string]*validateServer{"eu": {Host: "localhost", Port: 80}},
+	}
+	if !reflect.DeepEqual(c, expected) {
+		t.Fatalf("expecting %#v, got %#v", expected, c)
+	}
+}
+
+func Test_validate_violations(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	tbl := mergeTable(t, L, `{
+		primary = {port = 0},
+		servers = {{}, {port = -1}, {}},
+		zones = {["us-east"] = {port = 70000}},
+	}`)
+	_, err := lValueToReflect(L, tbl, reflect.TypeOf(validateConfig{}), nil)
+	verr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("expecting *ValidationError, got %v", err)
+	}
+
+	got := make(map[string]string)
+	for _, violation := range verr.Violations {
+		got[violation.Path] = violation.Err.Error()
+	}
+	expected := map[string]string{
+		"primary":          "port out of range",
+		"servers[2]":       "port out of range",
+		`zones["us-east"]`: "port out of range",
+		"name":             "required",
+		"servers":          "too many servers",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expecting %#v, got %#v", expected, got)
+	}
+
+	// nested structs are validated before the struct containing them
+	if last := verr.Violations[len(verr.Violations)-1].Path; last != "name" && last != "servers" {
+		t.Fatalf("unexpected last violation %s", last)
+	}
+
+	L.SetGlobal("set", New(L, func(v *validateServer) {}))
+	testError(t, L, `set({port = 0})`, "validation failed: port out of range")
+}
+
+type ValidateTestLimits struct {
+	Burst int
+	Rate  int
+}
+
+func (l *ValidateTestLimits) LuarDefaults() {
+	l.Rate = 10
+}
+
+type validateEmbedded struct {
+	Name string
+	*ValidateTestLimits
+}
+
+func Test_validate_merge_embedded(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	// nil embedded struct pointers allocated to merge promoted fields are
+	// given their defaults
+	var e validateEmbedded
+	if err := Merge(L, mergeTable(t, L, `{burst = 5}`), &e); err != nil {
+		t.Fatal(err)
+	}
+	if e.ValidateTestLimits == nil || *e.ValidateTestLimits != (ValidateTestLimits{Burst: 5, Rate: 10}) {
+		t.Fatalf("unexpected limits %#v", e.ValidateTestLimits)
+	}
+}
+
+func Test_validate_merge(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	c := validateConfig{Name: "x"}
+	if err := Merge(L, mergeTable(t, L, `{backup = {host = "b"}}`), &c); err != nil {
+		t.Fatal(err)
+	}
+	if *c.Backup != (validateServer{Host: "b", Port: 80}) {
+		t.Fatalf("unexpected backup %#v", c.Backup)
+	}
+
+	err := Merge(L, mergeTable(t, L, `{backup = {port = 0}}`), &c)
+	if err == nil || err.Error() != "validation failed: backup: port out of range" {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	GetConfig(L).MergeAssign = true
+	L.SetGlobal("c", New(L, &c))
+	primary := c.Primary
+	testError(t, L, `c.primary = {port = 0}`, "validation failed: port out of range")
+	if c.Primary != primary {
+		t.Fatalf("expecting primary to be unchanged, got %#v", c.Primary)
+	}
+}